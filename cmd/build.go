@@ -8,6 +8,7 @@ import (
 	"plenti/cmd/build"
 	"plenti/common"
 	"plenti/readers"
+	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -25,6 +26,36 @@ var BenchmarkFlag bool
 // NodeJSFlag let you use your systems NodeJS to build the site instead of core build.
 var NodeJSFlag bool
 
+// SizeBaselineFlag is the path to a file of output sizes that the build is compared against.
+var SizeBaselineFlag string
+
+// UpdateSizeBaselineFlag rewrites the size baseline file after intentional size changes.
+var UpdateSizeBaselineFlag bool
+
+// SizeToleranceFlag is the percentage an output size can grow before the build fails.
+var SizeToleranceFlag float64
+
+// FrozenFlag fails the build if the project inputs don't match plenti.lock.
+var FrozenFlag bool
+
+// OptimizeFlag turns on the recommended performance options and checks the result.
+var OptimizeFlag bool
+
+// NoMinifyHTMLFlag, NoCompressFlag, and NoPreloadFlag turn off individual options of --optimize.
+var NoMinifyHTMLFlag, NoCompressFlag, NoPreloadFlag bool
+
+// IncrementalFlag only rebuilds what changed since the last build.
+var IncrementalFlag bool
+
+// AnalyzeFlag reports what makes up the client JavaScript of each route.
+var AnalyzeFlag bool
+
+// Flags that change the output of a build, incremental builds start over when any of them change.
+func buildOptions(buildDir string) string {
+	return fmt.Sprintf("version=%s build=%s edit=%t nodejs=%t optimize=%t no-minify-html=%t no-compress=%t no-preload=%t",
+		Version, buildDir, EditFlag, NodeJSFlag, OptimizeFlag, NoMinifyHTMLFlag, NoCompressFlag, NoPreloadFlag)
+}
+
 func setBuildDir(siteConfig readers.SiteConfig) string {
 	buildDir := siteConfig.BuildDir
 	// Check if directory is overridden by flag.
@@ -54,6 +85,7 @@ func Build() {
 
 	build.CheckVerboseFlag(VerboseFlag)
 	build.CheckBenchmarkFlag(BenchmarkFlag)
+	build.CheckEditFlag(EditFlag)
 
 	// Handle panic when someone tries building outside of a valid Plenti site.
 	defer func() {
@@ -69,6 +101,14 @@ func Build() {
 	// Check flags and config for directory to build to.
 	buildDir := setBuildDir(siteConfig)
 
+	// The ejectable build.js only reads "content/", so it would build a different site.
+	if externalTypes := build.ExternalContentTypes(siteConfig); NodeJSFlag && len(externalTypes) > 0 {
+		log.Fatalf("Builds with --nodejs can only read the \"content/\" folder, but plenti.json reads %s from content_stores. Build without --nodejs to use content stores.\n", strings.Join(externalTypes, ", "))
+	}
+
+	// Record (or with --frozen, verify) the exact inputs used for this build.
+	lockHash := checkLock(siteConfig, FrozenFlag)
+
 	tempBuildDir := ""
 	var err error
 	// Get theme from plenti.json.
@@ -87,21 +127,6 @@ func Build() {
 	// Get the full path for the build directory of the site.
 	buildPath := filepath.Join(".", buildDir)
 
-	// Clear out any previous build dir of the same name.
-	if _, buildPathExistsErr := os.Stat(buildPath); buildPathExistsErr == nil {
-		build.Log("Removing old '" + buildPath + "' build directory")
-
-		common.CheckErr(os.RemoveAll(buildPath))
-	}
-
-	// Create the buildPath directory.
-	if err := os.MkdirAll(buildPath, os.ModePerm); err != nil {
-		// bail on error
-		log.Fatalf("Unable to create \"%v\" build directory: %s\n", buildDir, err)
-
-	}
-	build.Log("Creating '" + buildDir + "' build directory")
-
 	// Add core NPM dependencies if node_module folder doesn't already exist.
 	err = build.NpmDefaults(tempBuildDir)
 	common.CheckErr(err)
@@ -110,13 +135,38 @@ func Build() {
 	tempFiles, ejectedPath, err := build.EjectTemp(tempBuildDir)
 	common.CheckErr(err)
 
-	// Directly copy .js that don't need compiling to the build dir.
-	if err = build.EjectCopy(buildPath, tempBuildDir, ejectedPath); err != nil {
-		log.Fatal(err)
-	}
+	// Work out what changed since the last build (builds with NodeJS always start from scratch).
+	plan, err := build.PlanBuild(buildPath, siteConfig, tempBuildDir, ejectedPath, buildOptions(buildDir), IncrementalFlag && !NodeJSFlag)
+	common.CheckErr(err)
+	common.CheckErr(build.ClearBuildManifest())
+
+	if plan.Full {
+		// Clear out any previous build dir of the same name.
+		if _, buildPathExistsErr := os.Stat(buildPath); buildPathExistsErr == nil {
+			build.Log("Removing old '" + buildPath + "' build directory")
 
-	// Directly copy static assets to the build dir.
-	common.CheckErr(build.AssetsCopy(buildPath, tempBuildDir))
+			common.CheckErr(os.RemoveAll(buildPath))
+		}
+
+		// Create the buildPath directory.
+		if err := os.MkdirAll(buildPath, os.ModePerm); err != nil {
+			// bail on error
+			log.Fatalf("Unable to create \"%v\" build directory: %s\n", buildDir, err)
+
+		}
+		build.Log("Creating '" + buildDir + "' build directory")
+
+		// Directly copy .js that don't need compiling to the build dir.
+		if err = build.EjectCopy(buildPath, tempBuildDir, ejectedPath); err != nil {
+			log.Fatal(err)
+		}
+
+		// Directly copy static assets to the build dir.
+		common.CheckErr(build.AssetsCopy(buildPath, tempBuildDir))
+	} else {
+		// Only copy the static assets that changed.
+		common.CheckErr(build.AssetsUpdate(buildPath, tempBuildDir, plan))
+	}
 
 	// Run the build.js script using user local NodeJS.
 	if NodeJSFlag {
@@ -129,16 +179,35 @@ func Build() {
 	} else {
 
 		// Prep the client SPA.
-
-		common.CheckErr(build.Client(buildPath, tempBuildDir, ejectedPath))
+		if plan.Layout {
+			// Start the client SPA over so deleted components don't stick around.
+			common.CheckErr(build.RemoveClientBuild(buildPath))
+		}
+		if plan.Full || plan.Layout {
+			common.CheckErr(build.Client(buildPath, tempBuildDir, ejectedPath))
+		} else if plan.Content {
+			// Components haven't changed, they're only needed to render the HTML.
+			common.CheckErr(build.ClientSSR(buildPath, tempBuildDir, ejectedPath))
+		}
 
 		// Build JSON from "content/" directory.
-		common.CheckErr(build.DataSource(buildPath, siteConfig, tempBuildDir))
+		if plan.Full || plan.Layout {
+			common.CheckErr(build.DataSource(buildPath, siteConfig, tempBuildDir))
+		} else if plan.Content {
+			// Only render the pages of content that changed.
+			common.CheckErr(build.DataSourceChanged(buildPath, siteConfig, tempBuildDir, plan))
+		}
+		common.CheckErr(build.RemoveStalePages(buildPath, plan))
 
 	}
 
 	// Run Gopack (custom Snowpack alternative) for ESM support.
-	common.CheckErr(build.Gopack(buildPath))
+	if plan.Full {
+		common.CheckErr(build.Gopack(buildPath))
+	} else if plan.Layout {
+		// NPM dependencies can only change with package.json, which always runs a full build.
+		common.CheckErr(build.GopackComponents(buildPath))
+	}
 
 	if tempBuildDir != "" {
 		// If using themes, just delete the whole build folder.
@@ -148,6 +217,52 @@ func Build() {
 		common.CheckErr(build.EjectClean(tempFiles, ejectedPath))
 	}
 
+	// Apply the recommended performance options (set with --optimize or "optimize": true in plenti.json).
+	var optimizeOptions []build.OptimizeOption
+	optimize := OptimizeFlag || siteConfig.Optimize
+	if optimize {
+		optimizeOptions, err = build.OptimizeOptions(buildPath, siteConfig, map[string]bool{
+			build.OptimizeMinifyHTML: NoMinifyHTMLFlag,
+			build.OptimizeCompress:   NoCompressFlag,
+			build.OptimizePreload:    NoPreloadFlag,
+		})
+		common.CheckErr(err)
+		common.CheckErr(build.Optimize(buildPath, optimizeOptions))
+	}
+
+	// Break down the client JavaScript (runs after Gopack so the final import paths are used).
+	if AnalyzeFlag {
+		common.CheckErr(build.Analyze(buildPath))
+	}
+
+	// Stamp the build so deployed files can be traced back to plenti.lock.
+	common.CheckErr(build.BuildStamp(buildPath, build.Stamp{
+		Version:  Version,
+		Lock:     lockHash,
+		Optimize: build.EnabledOptions(optimizeOptions),
+	}))
+
+	// Make sure the optimized build still works.
+	if optimize {
+		if err = build.VerifyOptimized(buildPath, optimizeOptions); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// Record the inputs of this build so the next incremental build knows what changed.
+	common.CheckErr(build.WriteBuildManifest(buildPath, plan))
+
+	// Compare output sizes against a committed baseline file.
+	if SizeBaselineFlag != "" || UpdateSizeBaselineFlag {
+		sizeBaseline := SizeBaselineFlag
+		if sizeBaseline == "" {
+			sizeBaseline = "sizes.json"
+		}
+		if err = build.SizeBaseline(buildPath, sizeBaseline, SizeToleranceFlag, UpdateSizeBaselineFlag); err != nil {
+			log.Fatal(err)
+		}
+	}
+
 }
 
 func init() {
@@ -166,4 +281,14 @@ func init() {
 	buildCmd.Flags().BoolVarP(&VerboseFlag, "verbose", "v", false, "show log messages")
 	buildCmd.Flags().BoolVarP(&BenchmarkFlag, "benchmark", "b", false, "display build time statistics")
 	buildCmd.Flags().BoolVarP(&NodeJSFlag, "nodejs", "n", false, "use system nodejs for build with ejectable build.js script")
+	buildCmd.Flags().StringVar(&SizeBaselineFlag, "size-baseline", "", "compare output sizes against a baseline file, e.g. sizes.json")
+	buildCmd.Flags().BoolVar(&UpdateSizeBaselineFlag, "update-size-baseline", false, "rewrite the size baseline file with the sizes of this build")
+	buildCmd.Flags().Float64Var(&SizeToleranceFlag, "size-tolerance", 5, "percentage output sizes can grow before the build fails")
+	buildCmd.Flags().BoolVar(&FrozenFlag, "frozen", false, "fail if themes or core files don't match plenti.lock")
+	buildCmd.Flags().BoolVar(&OptimizeFlag, "optimize", false, "turn on the recommended performance options and verify the output")
+	buildCmd.Flags().BoolVar(&NoMinifyHTMLFlag, "no-minify-html", false, "don't collapse whitespace in html when optimizing")
+	buildCmd.Flags().BoolVar(&NoCompressFlag, "no-compress", false, "don't write precompressed .gz files when optimizing")
+	buildCmd.Flags().BoolVar(&NoPreloadFlag, "no-preload", false, "don't add modulepreload links when optimizing")
+	buildCmd.Flags().BoolVar(&IncrementalFlag, "incremental", false, "only rebuild what changed since the last build")
+	buildCmd.Flags().BoolVar(&AnalyzeFlag, "analyze", false, "report the size of client javascript by route and write a treemap to .plenti/analyze.html")
 }
@@ -0,0 +1,355 @@
+package build
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// BundleAnalysis breaks down the client JavaScript of a build by route.
+type BundleAnalysis struct {
+	Modules []AnalyzedModule
+	Routes  []RouteBundle
+	// Shared is loaded by every route (main.js and what it imports), by more than one route, or only dynamically.
+	Shared int64
+	// RouteSpecific is only loaded by a single route.
+	RouteSpecific int64
+	Duplicates    []DuplicateModule
+	// SingleRouteDeps are npm dependencies that only one route loads.
+	SingleRouteDeps []SingleRouteDep
+	// Upfront is everything main.js statically loads, including every component exported by layout.js.
+	// The browser downloads all of it on the first page, whichever route is visited.
+	Upfront int64
+}
+
+// AnalyzedModule is a module along with the routes that load it.
+type AnalyzedModule struct {
+	Path string
+	Size int64
+	// Routes that load the module, empty if it is only reachable through a dynamic import.
+	Routes []string
+	// Global modules are loaded by main.js on every route (not counting the components exported by layout.js).
+	Global bool
+}
+
+// RouteBundle is the JavaScript loaded by a single route.
+type RouteBundle struct {
+	Route    string
+	Entry    string
+	Modules  int
+	Total    int64
+	Specific int64
+}
+
+// DuplicateModule is the same module included from more than one path.
+type DuplicateModule struct {
+	Name  string
+	Paths []string
+	// Identical is true when every copy has the same content (otherwise they are different versions).
+	Identical bool
+	// Wasted is the size of every copy except the largest.
+	Wasted int64
+}
+
+// SingleRouteDep is an npm dependency in "web_modules/" that only one route needs.
+type SingleRouteDep struct {
+	Dependency string
+	Route      string
+	Size       int64
+}
+
+// Where the analyzer report is written, outside of the build directory so it never gets deployed.
+const analyzeReportPath = ".plenti/analyze.html"
+
+// Exports every component so main.js can look them up by name, which would make every component look global.
+const componentsModule = "/spa/ejected/layout.js"
+
+// Number of modules shown in the terminal summary.
+const analyzeTopModules = 10
+
+// Analyze walks the client module graph of each route and reports what makes up the JavaScript payload.
+func Analyze(buildPath string) error {
+
+	defer Benchmark(time.Now(), "Analyzing client bundle")
+
+	Log("\nAnalyzing client JavaScript in the '" + buildPath + "' build directory")
+
+	entries, err := ClientEntries(buildPath)
+	if err != nil {
+		return err
+	}
+	graph, err := ReadModuleGraph(buildPath, entries)
+	if err != nil {
+		return err
+	}
+	analysis := AnalyzeGraph(graph)
+
+	printAnalysis(analysis)
+
+	if err = os.MkdirAll(filepath.Dir(analyzeReportPath), os.ModePerm); err != nil {
+		return fmt.Errorf("Unable to create folder for analyzer report: %w", err)
+	}
+	if err = writeAnalyzeReport(analyzeReportPath, analysis); err != nil {
+		return err
+	}
+	fmt.Printf("\nTreemap report written to %s\n", analyzeReportPath)
+	return nil
+}
+
+// AnalyzeGraph works out which routes load each module of the graph.
+// The first entry is loaded on every route, the rest are the components of each route.
+// Components are attributed to the routes that import them rather than to layout.js, which main.js uses to import all of them.
+func AnalyzeGraph(graph ModuleGraph) BundleAnalysis {
+
+	analysis := BundleAnalysis{}
+
+	global := map[string]bool{}
+	for _, modulePath := range graph.reachableWithout(graph.Entries[0], componentsModule) {
+		global[modulePath] = true
+	}
+	upfront := map[string]bool{}
+	for _, modulePath := range graph.Reachable(graph.Entries[0]) {
+		upfront[modulePath] = true
+		analysis.Upfront += graph.Modules[modulePath].Size
+	}
+
+	moduleRoutes := map[string][]string{}
+	routeModules := map[string][]string{}
+	for _, entry := range graph.Entries[1:] {
+		route := strings.TrimSuffix(filepath.Base(entry), ".js")
+		routeModules[route] = graph.reachableWithout(entry, componentsModule)
+		for _, modulePath := range routeModules[route] {
+			moduleRoutes[modulePath] = append(moduleRoutes[modulePath], route)
+		}
+	}
+
+	for modulePath, module := range graph.Modules {
+		// Components that no route imports are only used through layout.js, which every route loads.
+		if upfront[modulePath] && len(moduleRoutes[modulePath]) == 0 {
+			global[modulePath] = true
+		}
+		analyzed := AnalyzedModule{
+			Path:   modulePath,
+			Size:   module.Size,
+			Routes: moduleRoutes[modulePath],
+			Global: global[modulePath],
+		}
+		if !analyzed.Global && len(analyzed.Routes) == 1 {
+			analysis.RouteSpecific += module.Size
+		} else {
+			analysis.Shared += module.Size
+		}
+		analysis.Modules = append(analysis.Modules, analyzed)
+	}
+	sort.Slice(analysis.Modules, func(i, j int) bool {
+		if analysis.Modules[i].Size != analysis.Modules[j].Size {
+			return analysis.Modules[i].Size > analysis.Modules[j].Size
+		}
+		return analysis.Modules[i].Path < analysis.Modules[j].Path
+	})
+
+	for _, entry := range graph.Entries[1:] {
+		route := strings.TrimSuffix(filepath.Base(entry), ".js")
+		bundle := RouteBundle{Route: route, Entry: entry}
+		loaded := map[string]bool{}
+		for modulePath := range global {
+			loaded[modulePath] = true
+		}
+		for _, modulePath := range routeModules[route] {
+			loaded[modulePath] = true
+			if !global[modulePath] && len(moduleRoutes[modulePath]) == 1 {
+				bundle.Specific += graph.Modules[modulePath].Size
+			}
+		}
+		for modulePath := range loaded {
+			bundle.Total += graph.Modules[modulePath].Size
+		}
+		bundle.Modules = len(loaded)
+		analysis.Routes = append(analysis.Routes, bundle)
+	}
+	sort.Slice(analysis.Routes, func(i, j int) bool {
+		if analysis.Routes[i].Total != analysis.Routes[j].Total {
+			return analysis.Routes[i].Total > analysis.Routes[j].Total
+		}
+		return analysis.Routes[i].Route < analysis.Routes[j].Route
+	})
+
+	analysis.Duplicates = findDuplicates(graph)
+	analysis.SingleRouteDeps = findSingleRouteDeps(analysis.Modules)
+
+	return analysis
+}
+
+// Finds modules with the same content, or the same file of the same package, at different paths.
+func findDuplicates(graph ModuleGraph) []DuplicateModule {
+	byHash := map[string][]string{}
+	byName := map[string][]string{}
+	for modulePath, module := range graph.Modules {
+		byHash[module.Hash] = append(byHash[module.Hash], modulePath)
+		if name := dependencyFile(modulePath); name != "" {
+			byName[name] = append(byName[name], modulePath)
+		}
+	}
+
+	duplicates := []DuplicateModule{}
+	reported := map[string]bool{}
+	addDuplicate := func(name string, paths []string) {
+		sort.Strings(paths)
+		key := strings.Join(paths, ",")
+		if len(paths) < 2 || reported[key] {
+			return
+		}
+		reported[key] = true
+		duplicate := DuplicateModule{Name: name, Paths: paths, Identical: true}
+		var largest int64
+		for _, modulePath := range paths {
+			module := graph.Modules[modulePath]
+			duplicate.Wasted += module.Size
+			if module.Size > largest {
+				largest = module.Size
+			}
+			if module.Hash != graph.Modules[paths[0]].Hash {
+				duplicate.Identical = false
+			}
+		}
+		duplicate.Wasted -= largest
+		duplicates = append(duplicates, duplicate)
+	}
+	for _, paths := range byHash {
+		addDuplicate(filepath.Base(paths[0]), paths)
+	}
+	for name, paths := range byName {
+		addDuplicate(name, paths)
+	}
+
+	sort.Slice(duplicates, func(i, j int) bool {
+		if duplicates[i].Wasted != duplicates[j].Wasted {
+			return duplicates[i].Wasted > duplicates[j].Wasted
+		}
+		return duplicates[i].Paths[0] < duplicates[j].Paths[0]
+	})
+	return duplicates
+}
+
+// Totals the dependencies (grouped by npm package) where every module is only loaded by one route.
+func findSingleRouteDeps(modules []AnalyzedModule) []SingleRouteDep {
+	deps := map[string]*SingleRouteDep{}
+	shared := map[string]bool{}
+	for _, module := range modules {
+		dependency := dependencyName(module.Path)
+		if dependency == "" {
+			continue
+		}
+		if module.Global || len(module.Routes) != 1 {
+			shared[dependency] = true
+			continue
+		}
+		if dep, ok := deps[dependency]; ok {
+			if dep.Route != module.Routes[0] {
+				// Different modules of the package are used by different routes.
+				shared[dependency] = true
+			}
+			dep.Size += module.Size
+			continue
+		}
+		deps[dependency] = &SingleRouteDep{Dependency: dependency, Route: module.Routes[0], Size: module.Size}
+	}
+
+	singleRouteDeps := []SingleRouteDep{}
+	for dependency, dep := range deps {
+		if !shared[dependency] {
+			singleRouteDeps = append(singleRouteDeps, *dep)
+		}
+	}
+	sort.Slice(singleRouteDeps, func(i, j int) bool {
+		if singleRouteDeps[i].Size != singleRouteDeps[j].Size {
+			return singleRouteDeps[i].Size > singleRouteDeps[j].Size
+		}
+		return singleRouteDeps[i].Dependency < singleRouteDeps[j].Dependency
+	})
+	return singleRouteDeps
+}
+
+// Gets the npm package a module was copied from by Gopack, e.g. "svelte" or "@scope/name" (empty for project files).
+func dependencyName(modulePath string) string {
+	name := dependencyFile(modulePath)
+	if name == "" {
+		return ""
+	}
+	parts := strings.Split(name, "/")
+	if strings.HasPrefix(parts[0], "@") && len(parts) > 1 {
+		return parts[0] + "/" + parts[1]
+	}
+	return parts[0]
+}
+
+// Gets the path of a module within its npm package, using the innermost node_modules for nested dependencies.
+func dependencyFile(modulePath string) string {
+	if !strings.HasPrefix(modulePath, "/spa/web_modules/") {
+		return ""
+	}
+	name := strings.TrimPrefix(modulePath, "/spa/web_modules/")
+	if i := strings.LastIndex(name, "/node_modules/"); i >= 0 {
+		name = name[i+len("/node_modules/"):]
+	}
+	return name
+}
+
+func printAnalysis(analysis BundleAnalysis) {
+
+	fmt.Printf("\nClient JavaScript: %d modules, %s shared, %s route-specific\n",
+		len(analysis.Modules), formatBytes(analysis.Shared), formatBytes(analysis.RouteSpecific))
+	fmt.Printf("Modules are attributed to the routes that import them, but main.js imports every component through layout.js, so %s is loaded on the first page of any route\n",
+		formatBytes(analysis.Upfront))
+
+	fmt.Println("\nRoutes:")
+	for _, route := range analysis.Routes {
+		fmt.Printf("  %-20s total %-10s route-specific %-10s (%d modules)\n",
+			route.Route, formatBytes(route.Total), formatBytes(route.Specific), route.Modules)
+	}
+
+	fmt.Println("\nTop modules:")
+	for i, module := range analysis.Modules {
+		if i == analyzeTopModules {
+			break
+		}
+		fmt.Printf("  %-10s %s (%s)\n", formatBytes(module.Size), module.Path, moduleUsage(module))
+	}
+
+	if len(analysis.Duplicates) > 0 {
+		fmt.Println("\nDuplicate modules:")
+		for _, duplicate := range analysis.Duplicates {
+			kind := "different versions"
+			if duplicate.Identical {
+				kind = "identical copies"
+			}
+			fmt.Printf("  %s, %s, %s could be saved:\n", duplicate.Name, kind, formatBytes(duplicate.Wasted))
+			for _, modulePath := range duplicate.Paths {
+				fmt.Println("    " + modulePath)
+			}
+		}
+	}
+
+	if len(analysis.SingleRouteDeps) > 0 {
+		fmt.Println("\nDependencies used by only one route:")
+		for _, dep := range analysis.SingleRouteDeps {
+			fmt.Printf("  %-20s %-10s only in %s\n", dep.Dependency, formatBytes(dep.Size), dep.Route)
+		}
+	}
+}
+
+// Describes which routes load a module.
+func moduleUsage(module AnalyzedModule) string {
+	switch {
+	case module.Global:
+		return "every route"
+	case len(module.Routes) == 0:
+		return "dynamic import only"
+	case len(module.Routes) == 1:
+		return "only " + module.Routes[0]
+	}
+	return fmt.Sprintf("%d routes", len(module.Routes))
+}
@@ -0,0 +1,279 @@
+package build
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Dimensions of the treemap in the analyzer report.
+const (
+	treemapWidth  = 1200.0
+	treemapHeight = 700.0
+	// Height of the label strip at the top of each folder.
+	treemapHeader = 16.0
+)
+
+// A file or folder of the treemap.
+type treemapNode struct {
+	name     string
+	path     string
+	size     int64
+	module   *AnalyzedModule
+	children map[string]*treemapNode
+}
+
+// A rectangle drawn in the treemap.
+type treemapRect struct {
+	X, Y, W, H float64
+	Label      string
+	Title      string
+	Class      string
+}
+
+// Writes a report with a treemap of every module that doesn't need anything outside the file to view.
+func writeAnalyzeReport(reportPath string, analysis BundleAnalysis) error {
+
+	duplicates := map[string]bool{}
+	for _, duplicate := range analysis.Duplicates {
+		for _, modulePath := range duplicate.Paths {
+			duplicates[modulePath] = true
+		}
+	}
+
+	root := &treemapNode{name: "/", path: "", children: map[string]*treemapNode{}}
+	for i := range analysis.Modules {
+		module := &analysis.Modules[i]
+		node := root
+		for _, part := range strings.Split(strings.TrimPrefix(module.Path, "/"), "/") {
+			node.size += module.Size
+			child, ok := node.children[part]
+			if !ok {
+				child = &treemapNode{name: part, path: node.path + "/" + part, children: map[string]*treemapNode{}}
+				node.children[part] = child
+			}
+			node = child
+		}
+		node.size += module.Size
+		node.module = module
+	}
+
+	rects := []treemapRect{}
+	var place func(node *treemapNode, x, y, w, h float64)
+	place = func(node *treemapNode, x, y, w, h float64) {
+		if w < 1 || h < 1 {
+			return
+		}
+		if node.module != nil {
+			class := "shared"
+			if !node.module.Global && len(node.module.Routes) == 1 {
+				class = "specific"
+			}
+			if duplicates[node.module.Path] {
+				class = "duplicate"
+			}
+			rects = append(rects, treemapRect{
+				X: x, Y: y, W: w, H: h,
+				Label: node.name,
+				Title: fmt.Sprintf("%s\n%s, %s", node.path, formatBytes(node.size), moduleUsage(*node.module)),
+				Class: class,
+			})
+			return
+		}
+		rects = append(rects, treemapRect{
+			X: x, Y: y, W: w, H: h,
+			Label: node.name,
+			Title: fmt.Sprintf("%s/\n%s", node.path, formatBytes(node.size)),
+			Class: "folder",
+		})
+		// Leave room for the folder name when there is space for it.
+		header := 0.0
+		if h > treemapHeader*2 {
+			header = treemapHeader
+		}
+		children := []*treemapNode{}
+		for _, child := range node.children {
+			children = append(children, child)
+		}
+		squarify(children, x+1, y+header, w-2, h-header-1, place)
+	}
+	place(root, 0, 0, treemapWidth, treemapHeight)
+
+	var report bytes.Buffer
+	err := analyzeTemplate.Execute(&report, map[string]interface{}{
+		"Analysis": analysis,
+		"Rects":    rects,
+		"Width":    treemapWidth,
+		"Height":   treemapHeight,
+	})
+	if err != nil {
+		return fmt.Errorf("Unable to create analyzer report: %w", err)
+	}
+	if err = ioutil.WriteFile(reportPath, report.Bytes(), 0644); err != nil {
+		return fmt.Errorf("Unable to write analyzer report %s: %w", reportPath, err)
+	}
+	return nil
+}
+
+// Lays out nodes in rows so each rectangle stays as close to a square as possible.
+func squarify(nodes []*treemapNode, x, y, w, h float64, place func(*treemapNode, float64, float64, float64, float64)) {
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].size != nodes[j].size {
+			return nodes[i].size > nodes[j].size
+		}
+		return nodes[i].name < nodes[j].name
+	})
+	var total int64
+	for _, node := range nodes {
+		total += node.size
+	}
+	if total == 0 || w <= 0 || h <= 0 {
+		return
+	}
+	scale := w * h / float64(total)
+
+	for len(nodes) > 0 {
+		side := math.Min(w, h)
+		count := 1
+		for count < len(nodes) && worstRatio(nodes[:count+1], side, scale) <= worstRatio(nodes[:count], side, scale) {
+			count++
+		}
+		row := nodes[:count]
+		nodes = nodes[count:]
+
+		var rowArea float64
+		for _, node := range row {
+			rowArea += float64(node.size) * scale
+		}
+		thickness := rowArea / side
+		offset := 0.0
+		for _, node := range row {
+			length := float64(node.size) * scale / thickness
+			if w >= h {
+				// Fill a column on the left.
+				place(node, x, y+offset, thickness, length)
+			} else {
+				// Fill a row along the top.
+				place(node, x+offset, y, length, thickness)
+			}
+			offset += length
+		}
+		if w >= h {
+			x += thickness
+			w -= thickness
+		} else {
+			y += thickness
+			h -= thickness
+		}
+	}
+}
+
+// The most stretched aspect ratio in a row of nodes laid out along a side.
+func worstRatio(row []*treemapNode, side float64, scale float64) float64 {
+	var sum, largest float64
+	smallest := math.Inf(1)
+	for _, node := range row {
+		area := float64(node.size) * scale
+		sum += area
+		largest = math.Max(largest, area)
+		smallest = math.Min(smallest, area)
+	}
+	if sum == 0 || smallest == 0 {
+		return math.Inf(1)
+	}
+	return math.Max(side*side*largest/(sum*sum), sum*sum/(side*side*smallest))
+}
+
+var analyzeTemplate = template.Must(template.New("analyze").Funcs(template.FuncMap{
+	"bytes": formatBytes,
+	"usage": moduleUsage,
+	// Only label rectangles that are big enough to fit some text.
+	"fits": func(rect treemapRect) bool { return rect.W > 40 && rect.H > 14 },
+	"trim": func(rect treemapRect) string {
+		maxChars := int(rect.W / 7)
+		if len(rect.Label) > maxChars {
+			return rect.Label[:maxChars-1] + "…"
+		}
+		return rect.Label
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Plenti bundle analysis</title>
+<style>
+body { font-family: sans-serif; margin: 20px; color: #111; }
+table { border-collapse: collapse; margin-bottom: 30px; }
+th, td { text-align: left; padding: 4px 12px 4px 0; }
+td.size { text-align: right; }
+svg { max-width: 100%; height: auto; }
+svg text { font-size: 11px; pointer-events: none; }
+.folder { fill: #f4f4f4; stroke: #bbb; }
+.shared { fill: #8ec5ea; stroke: white; }
+.specific { fill: #a6dba0; stroke: white; }
+.duplicate { fill: #f4a582; stroke: white; }
+.legend span { display: inline-block; width: 12px; height: 12px; margin: 0 4px 0 12px; vertical-align: middle; }
+</style>
+</head>
+<body>
+<h1>Client JavaScript</h1>
+<p>{{len .Analysis.Modules}} modules, {{bytes .Analysis.Shared}} shared, {{bytes .Analysis.RouteSpecific}} route-specific.
+Modules are attributed to the routes that import them, but main.js imports every component through layout.js, so {{bytes .Analysis.Upfront}} is loaded on the first page of any route.</p>
+<p class="legend">
+<span style="background: #8ec5ea"></span>shared
+<span style="background: #a6dba0"></span>route-specific
+<span style="background: #f4a582"></span>duplicate
+</p>
+<svg viewBox="0 0 {{.Width}} {{.Height}}" width="{{.Width}}" height="{{.Height}}">
+{{- range .Rects}}
+<g><title>{{.Title}}</title><rect x="{{printf "%.1f" .X}}" y="{{printf "%.1f" .Y}}" width="{{printf "%.1f" .W}}" height="{{printf "%.1f" .H}}" class="{{.Class}}"></rect>
+{{- if fits .}}<text x="{{printf "%.1f" .X}}" y="{{printf "%.1f" .Y}}" dx="3" dy="12">{{trim .}}</text>{{end}}</g>
+{{- end}}
+</svg>
+
+<h2>Routes</h2>
+<table>
+<tr><th>Route</th><th>Entry</th><th>Modules</th><th>Total</th><th>Route-specific</th></tr>
+{{- range .Analysis.Routes}}
+<tr><td>{{.Route}}</td><td>{{.Entry}}</td><td>{{.Modules}}</td><td class="size">{{bytes .Total}}</td><td class="size">{{bytes .Specific}}</td></tr>
+{{- end}}
+</table>
+
+<h2>Duplicate modules</h2>
+{{- if .Analysis.Duplicates}}
+<table>
+<tr><th>Module</th><th>Copies</th><th>Could save</th></tr>
+{{- range .Analysis.Duplicates}}
+<tr><td>{{.Name}} ({{if .Identical}}identical copies{{else}}different versions{{end}})</td><td>{{range .Paths}}{{.}}<br>{{end}}</td><td class="size">{{bytes .Wasted}}</td></tr>
+{{- end}}
+</table>
+{{- else}}
+<p>None found.</p>
+{{- end}}
+
+<h2>Dependencies used by only one route</h2>
+{{- if .Analysis.SingleRouteDeps}}
+<table>
+<tr><th>Dependency</th><th>Route</th><th>Size</th></tr>
+{{- range .Analysis.SingleRouteDeps}}
+<tr><td>{{.Dependency}}</td><td>{{.Route}}</td><td class="size">{{bytes .Size}}</td></tr>
+{{- end}}
+</table>
+{{- else}}
+<p>None found.</p>
+{{- end}}
+
+<h2>Modules</h2>
+<table>
+<tr><th>Module</th><th>Size</th><th>Loaded by</th></tr>
+{{- range .Analysis.Modules}}
+<tr><td>{{.Path}}</td><td class="size">{{bytes .Size}}</td><td>{{usage .}}</td></tr>
+{{- end}}
+</table>
+</body>
+</html>
+`))
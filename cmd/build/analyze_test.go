@@ -0,0 +1,104 @@
+package build
+
+import (
+	"reflect"
+	"testing"
+)
+
+// A client app like the one Client builds: main.js imports every component through layout.js.
+func testClientGraph() ModuleGraph {
+	modules := []*Module{
+		{Path: "/spa/ejected/main.js", Size: 10, Imports: []string{"/spa/ejected/router.js", "/spa/ejected/layout.js"}},
+		{Path: "/spa/ejected/router.js", Size: 20, Imports: []string{"/spa/global/html.js", "/spa/web_modules/navaid/dist/navaid.js"}},
+		{Path: "/spa/web_modules/navaid/dist/navaid.js", Size: 5},
+		{Path: "/spa/global/html.js", Size: 30, Imports: []string{"/spa/web_modules/svelte/internal/index.js"}},
+		{Path: "/spa/web_modules/svelte/internal/index.js", Size: 100},
+		{Path: "/spa/ejected/layout.js", Size: 1, Imports: []string{
+			"/spa/global/html.js", "/spa/content/blog.js", "/spa/content/pages.js",
+			"/spa/components/grid.js", "/spa/components/chart.js", "/spa/components/unused.js",
+		}},
+		{Path: "/spa/content/blog.js", Size: 40, Imports: []string{"/spa/components/grid.js", "/spa/web_modules/svelte/internal/index.js"}},
+		{Path: "/spa/content/pages.js", Size: 50, Imports: []string{"/spa/components/grid.js", "/spa/components/chart.js"}},
+		{Path: "/spa/components/grid.js", Size: 60},
+		{Path: "/spa/components/chart.js", Size: 70, Imports: []string{"/spa/web_modules/chartjs/chart.js"}},
+		{Path: "/spa/web_modules/chartjs/chart.js", Size: 200},
+		{Path: "/spa/components/unused.js", Size: 3},
+	}
+	graph := ModuleGraph{
+		Entries: []string{"/spa/ejected/main.js", "/spa/content/blog.js", "/spa/content/pages.js"},
+		Modules: map[string]*Module{},
+	}
+	for _, module := range modules {
+		graph.Modules[module.Path] = module
+	}
+	return graph
+}
+
+func TestAnalyzeGraph(t *testing.T) {
+	analysis := AnalyzeGraph(testClientGraph())
+
+	modules := map[string]AnalyzedModule{}
+	for _, module := range analysis.Modules {
+		modules[module.Path] = module
+	}
+	tests := []struct {
+		path   string
+		global bool
+		routes []string
+	}{
+		{"/spa/ejected/main.js", true, nil},
+		{"/spa/ejected/layout.js", true, nil},
+		{"/spa/global/html.js", true, nil},
+		{"/spa/web_modules/svelte/internal/index.js", true, []string{"blog"}},
+		{"/spa/content/blog.js", false, []string{"blog"}},
+		{"/spa/components/grid.js", false, []string{"blog", "pages"}},
+		{"/spa/components/chart.js", false, []string{"pages"}},
+		{"/spa/web_modules/chartjs/chart.js", false, []string{"pages"}},
+		// Only reachable through layout.js, so it's loaded everywhere but no route can be blamed for it.
+		{"/spa/components/unused.js", true, nil},
+	}
+	for _, test := range tests {
+		module := modules[test.path]
+		if module.Global != test.global || !reflect.DeepEqual(module.Routes, test.routes) {
+			t.Errorf("%s: global %v, routes %v, want global %v, routes %v", test.path, module.Global, module.Routes, test.global, test.routes)
+		}
+	}
+
+	// main.js, router, navaid, html, svelte, layout.js, unused, grid (loaded by both routes).
+	if analysis.Shared != 10+20+5+30+100+1+3+60 {
+		t.Errorf("shared = %d", analysis.Shared)
+	}
+	// blog.js, pages.js, chart and chartjs.
+	if analysis.RouteSpecific != 40+50+70+200 {
+		t.Errorf("route-specific = %d", analysis.RouteSpecific)
+	}
+	if analysis.Upfront != 589 {
+		t.Errorf("upfront = %d, want every module", analysis.Upfront)
+	}
+
+	routes := map[string]RouteBundle{}
+	for _, route := range analysis.Routes {
+		routes[route.Route] = route
+	}
+	if blog := routes["blog"]; blog.Specific != 40 || blog.Total != 169+40+60 || blog.Modules != 9 {
+		t.Errorf("blog = %+v", blog)
+	}
+	if pages := routes["pages"]; pages.Specific != 50+70+200 || pages.Total != 169+50+60+70+200 || pages.Modules != 11 {
+		t.Errorf("pages = %+v", pages)
+	}
+
+	if !reflect.DeepEqual(analysis.SingleRouteDeps, []SingleRouteDep{{Dependency: "chartjs", Route: "pages", Size: 200}}) {
+		t.Errorf("single route deps = %+v", analysis.SingleRouteDeps)
+	}
+}
+
+func TestReachable(t *testing.T) {
+	graph := testClientGraph()
+	if reachable := graph.Reachable("/spa/ejected/main.js"); len(reachable) != len(graph.Modules) {
+		t.Errorf("main.js reaches %d modules, want all %d", len(reachable), len(graph.Modules))
+	}
+	want := []string{"/spa/components/chart.js", "/spa/components/grid.js", "/spa/content/pages.js", "/spa/web_modules/chartjs/chart.js"}
+	if reachable := graph.Reachable("/spa/content/pages.js"); !reflect.DeepEqual(reachable, want) {
+		t.Errorf("pages.js reaches %v, want %v", reachable, want)
+	}
+}
@@ -65,3 +65,51 @@ func AssetsCopy(buildPath string, tempBuildDir string) error {
 	return nil
 
 }
+
+// AssetsUpdate copies only the static assets that changed since the last build and removes deleted ones.
+func AssetsUpdate(buildPath string, tempBuildDir string, plan BuildPlan) error {
+
+	defer Benchmark(time.Now(), "Updating changed static assets in build dir")
+
+	for _, asset := range plan.RemovedAssets {
+		Log("Removing deleted asset " + asset)
+		if err := removeOutput(buildPath, "/"+asset); err != nil {
+			return err
+		}
+	}
+
+	for _, asset := range plan.Assets {
+		Log("Copying changed asset " + asset)
+		destPath := buildPath + "/" + asset
+		if err := os.MkdirAll(filepath.Dir(destPath), os.ModePerm); err != nil {
+			return err
+		}
+		if err := copyAsset(tempBuildDir+asset, destPath); err != nil {
+			return err
+		}
+	}
+
+	Log(fmt.Sprintf("Number of assets updated: %d", len(plan.Assets)+len(plan.RemovedAssets)))
+	return nil
+
+}
+
+// Copies a single asset, closing both files before the next one is opened.
+func copyAsset(srcPath string, destPath string) error {
+	from, err := os.Open(srcPath)
+	if err != nil {
+		return fmt.Errorf("Could not open asset for copying: %w", err)
+	}
+	defer from.Close()
+
+	to, err := os.Create(destPath)
+	if err != nil {
+		return fmt.Errorf("Could not create destination asset for copying: %w", err)
+	}
+	defer to.Close()
+
+	if _, err = io.Copy(to, from); err != nil {
+		return fmt.Errorf("Could not copy asset from source to destination: %w", err)
+	}
+	return nil
+}
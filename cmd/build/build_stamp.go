@@ -0,0 +1,32 @@
+package build
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"time"
+)
+
+// Stamp records how a build was made so deployed files can be traced back to their inputs.
+type Stamp struct {
+	Version string `json:"version"`
+	Lock    string `json:"lock"`
+	Built   string `json:"built"`
+	// Optimize lists the performance options the build was made with.
+	Optimize []string `json:"optimize,omitempty"`
+}
+
+// BuildStamp writes the plenti_stamp.json file to the root of the build directory.
+func BuildStamp(buildPath string, stamp Stamp) error {
+
+	stamp.Built = time.Now().UTC().Format(time.RFC3339)
+
+	result, err := json.MarshalIndent(stamp, "", "\t")
+	if err != nil {
+		return fmt.Errorf("Unable to marshal build stamp: %w", err)
+	}
+	if err = ioutil.WriteFile(buildPath+"/plenti_stamp.json", result, 0644); err != nil {
+		return fmt.Errorf("Unable to write build stamp: %w", err)
+	}
+	return nil
+}
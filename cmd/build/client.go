@@ -17,6 +17,16 @@ import (
 // SSRctx is a v8go context for loaded with components needed to render HTML.
 var SSRctx *v8go.Context
 
+// Only compile components for SSR, leaving the client SPA that's already in the build dir as is.
+var ssrOnly bool
+
+// ClientSSR loads components into SSRctx without writing the client SPA (used by incremental builds when only content changed).
+func ClientSSR(buildPath string, tempBuildDir string, ejectedPath string) error {
+	ssrOnly = true
+	defer func() { ssrOnly = false }()
+	return Client(buildPath, tempBuildDir, ejectedPath)
+}
+
 // Client builds the SPA.
 func Client(buildPath string, tempBuildDir string, ejectedPath string) error {
 
@@ -104,6 +114,11 @@ func Client(buildPath string, tempBuildDir string, ejectedPath string) error {
 		return err
 	}
 
+	// Compile form components if any forms are declared in plenti.json.
+	if err = compileForms(ctx, SSRctx, buildPath, stylePath, tempBuildDir, ejectedPath); err != nil {
+		return err
+	}
+
 	// Go through all file paths in the "/layout" folder.
 	err = filepath.Walk(tempBuildDir+"layout", func(layoutPath string, layoutFileInfo os.FileInfo, err error) error {
 		// Create destination path.
@@ -146,10 +161,12 @@ func Client(buildPath string, tempBuildDir string, ejectedPath string) error {
 	}
 
 	// Write layout.js to filesystem.
-	err = ioutil.WriteFile(buildPath+"/spa/ejected/layout.js", []byte(allComponentsStr), os.ModePerm)
-	if err != nil {
-		return fmt.Errorf("Unable to write layout.js file: %w", err)
+	if !ssrOnly {
+		err = ioutil.WriteFile(buildPath+"/spa/ejected/layout.js", []byte(allComponentsStr), os.ModePerm)
+		if err != nil {
+			return fmt.Errorf("Unable to write layout.js file: %w", err)
 
+		}
 	}
 
 	Log("Number of components compiled: " + strconv.Itoa(compiledComponentCounter))
@@ -165,37 +182,39 @@ func compileSvelte(ctx *v8go.Context, SSRctx *v8go.Context, layoutPath string,
 	}
 	componentStr := string(component)
 
-	// Compile component with Svelte.
-	_, err = ctx.RunScript("var { js, css } = svelte.compile(`"+componentStr+"`, {css: false, hydratable: true});", "compile_svelte")
-	if err != nil {
-		return err
-	}
-	// Get the JS code from the compiled result.
-	jsCode, err := ctx.RunScript("js.code;", "compile_svelte")
-	if err != nil {
-		return fmt.Errorf("V8go could not execute js.code: %w", err)
-	}
-	jsBytes := []byte(jsCode.String())
-	err = ioutil.WriteFile(destFile, jsBytes, 0755)
-	if err != nil {
-		return fmt.Errorf("Unable to write compiled client file: %w", err)
-	}
+	if !ssrOnly {
+		// Compile component with Svelte.
+		_, err = ctx.RunScript("var { js, css } = svelte.compile(`"+componentStr+"`, {css: false, hydratable: true});", "compile_svelte")
+		if err != nil {
+			return err
+		}
+		// Get the JS code from the compiled result.
+		jsCode, err := ctx.RunScript("js.code;", "compile_svelte")
+		if err != nil {
+			return fmt.Errorf("V8go could not execute js.code: %w", err)
+		}
+		jsBytes := []byte(jsCode.String())
+		err = ioutil.WriteFile(destFile, jsBytes, 0755)
+		if err != nil {
+			return fmt.Errorf("Unable to write compiled client file: %w", err)
+		}
 
-	// Get the CSS code from the compiled result.
-	cssCode, err := ctx.RunScript("css.code;", "compile_svelte")
-	if err != nil {
-		return fmt.Errorf("V8go could not execute css.code: %w", err)
-	}
-	cssStr := strings.TrimSpace(cssCode.String())
-	// If there is CSS, write it into the bundle.css file.
-	if cssStr != "null" {
-		cssFile, err := os.OpenFile(stylePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		// Get the CSS code from the compiled result.
+		cssCode, err := ctx.RunScript("css.code;", "compile_svelte")
 		if err != nil {
-			return fmt.Errorf("Could not open bundle.css for writing: %w", err)
+			return fmt.Errorf("V8go could not execute css.code: %w", err)
 		}
-		defer cssFile.Close()
-		if _, err := cssFile.WriteString(cssStr); err != nil {
-			return fmt.Errorf("could not write to cssStr: %w", err)
+		cssStr := strings.TrimSpace(cssCode.String())
+		// If there is CSS, write it into the bundle.css file.
+		if cssStr != "null" {
+			cssFile, err := os.OpenFile(stylePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+			if err != nil {
+				return fmt.Errorf("Could not open bundle.css for writing: %w", err)
+			}
+			defer cssFile.Close()
+			if _, err := cssFile.WriteString(cssStr); err != nil {
+				return fmt.Errorf("could not write to cssStr: %w", err)
+			}
 		}
 	}
 
@@ -0,0 +1,238 @@
+package build
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"plenti/readers"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ContentNode describes a single piece of content provided by a ContentStore.
+type ContentNode struct {
+	// Type is the content type the node belongs to, e.g. "blog".
+	Type string
+	// Path is where the node lives within the store, formatted like "/blog/post1.json".
+	Path string
+	// Filename is the name of the node, e.g. "post1.json".
+	Filename string
+	// Source is the location of an editable file relative to "content/" (empty if the node can't be edited).
+	Source string
+}
+
+// ContentStore reads content from wherever it is kept, so the data source doesn't have to care.
+type ContentStore interface {
+	// Types lists the content types found in the store.
+	Types() ([]string, error)
+	// Nodes lists every node of a content type.
+	Nodes(contentType string) ([]ContentNode, error)
+	// Fields gets the JSON encoded field values of a node.
+	Fields(node ContentNode) ([]byte, error)
+	// Watch calls onChange when content in the store changes (stores that fsnotify can watch do nothing here).
+	Watch(onChange func()) error
+}
+
+// ContentStores creates the stores configured in plenti.json, falling back to the "content/" folder for every other type.
+func ContentStores(siteConfig readers.SiteConfig, tempBuildDir string) ([]ContentStore, error) {
+
+	configuredTypes := []string{}
+	for contentType := range siteConfig.ContentStores {
+		configuredTypes = append(configuredTypes, contentType)
+	}
+	sort.Strings(configuredTypes)
+
+	stores := []ContentStore{
+		&fsStore{dir: tempBuildDir + "content", exclude: configuredTypes},
+	}
+	for _, contentType := range configuredTypes {
+		options := siteConfig.ContentStores[contentType]
+		switch options.Store {
+		case "sqlite":
+			stores = append(stores, &sqliteStore{contentType: contentType, options: options})
+		case "yaml":
+			stores = append(stores, &yamlStore{contentType: contentType, dir: options.Path})
+		case "", "json":
+			// Type is explicitly read from the "content/" folder.
+			stores[0].(*fsStore).exclude = removeType(stores[0].(*fsStore).exclude, contentType)
+		default:
+			return nil, fmt.Errorf("Unknown content store '%s' for type '%s', use json, sqlite, or yaml", options.Store, contentType)
+		}
+	}
+	return stores, nil
+}
+
+// ExternalContentTypes lists the types plenti.json reads from somewhere other than the "content/" folder.
+func ExternalContentTypes(siteConfig readers.SiteConfig) []string {
+	types := []string{}
+	for contentType, options := range siteConfig.ContentStores {
+		if options.Store != "" && options.Store != "json" {
+			types = append(types, contentType)
+		}
+	}
+	sort.Strings(types)
+	return types
+}
+
+// WatchContentStores calls onChange when content changes in a store that fsnotify can't watch.
+func WatchContentStores(siteConfig readers.SiteConfig, onChange func()) error {
+	stores, err := ContentStores(siteConfig, "")
+	if err != nil {
+		return err
+	}
+	for _, store := range stores {
+		if err = store.Watch(onChange); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Calls addNode with the fields of every node of every type in the stores.
+func eachContentNode(stores []ContentStore, addNode func(ContentNode, []byte) error) error {
+	for _, store := range stores {
+		types, err := store.Types()
+		if err != nil {
+			return err
+		}
+		for _, contentType := range types {
+			nodes, err := store.Nodes(contentType)
+			if err != nil {
+				return err
+			}
+			for _, node := range nodes {
+				fields, err := store.Fields(node)
+				if err != nil {
+					return err
+				}
+				if err = addNode(node, fields); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func removeType(contentTypes []string, remove string) []string {
+	kept := []string{}
+	for _, contentType := range contentTypes {
+		if contentType != remove {
+			kept = append(kept, contentType)
+		}
+	}
+	return kept
+}
+
+// Store for JSON files in the "content/" folder (the default).
+type fsStore struct {
+	dir     string
+	exclude []string
+}
+
+func (s *fsStore) Types() ([]string, error) {
+	entries, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read content folder: %w", err)
+	}
+	types := []string{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if name[:1] == "_" || name[:1] == "." {
+			continue
+		}
+		// Files at the top level are single types, e.g. "index.json" is the "index" type.
+		if !entry.IsDir() {
+			name = strings.TrimSuffix(name, filepath.Ext(name))
+		}
+		if !s.excluded(name) {
+			types = append(types, name)
+		}
+	}
+	return types, nil
+}
+
+func (s *fsStore) excluded(contentType string) bool {
+	for _, excluded := range s.exclude {
+		if excluded == contentType {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *fsStore) Nodes(contentType string) ([]ContentNode, error) {
+	nodes := []ContentNode{}
+	typeDir := s.dir + "/" + contentType
+	if info, err := os.Stat(typeDir); err != nil || !info.IsDir() {
+		// Single types are a file at the top level instead of a folder.
+		matches, _ := filepath.Glob(typeDir + ".*")
+		for _, match := range matches {
+			nodes = append(nodes, s.node(contentType, match))
+		}
+		return nodes, nil
+	}
+	err := filepath.Walk(typeDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		// Don't add _blueprint.json or other special named files starting with underscores.
+		if !info.IsDir() && info.Name()[:1] != "_" && info.Name()[:1] != "." {
+			nodes = append(nodes, s.node(contentType, path))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Could not get content file: %w", err)
+	}
+	return nodes, nil
+}
+
+func (s *fsStore) node(contentType string, path string) ContentNode {
+	relativePath := strings.TrimPrefix(path, s.dir)
+	return ContentNode{
+		Type:     contentType,
+		Path:     relativePath,
+		Filename: filepath.Base(path),
+		Source:   strings.TrimPrefix(relativePath, "/"),
+	}
+}
+
+func (s *fsStore) Fields(node ContentNode) ([]byte, error) {
+	fields, err := ioutil.ReadFile(s.dir + node.Path)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read content file: %w", err)
+	}
+	return fields, nil
+}
+
+// Watch does nothing because the serve command already watches "content/" with fsnotify.
+func (s *fsStore) Watch(onChange func()) error {
+	return nil
+}
+
+// Polls a signature of the store's content for stores that can't be watched with fsnotify.
+func pollChanges(signature func() string, onChange func()) {
+	last := signature()
+	go func() {
+		for range time.Tick(time.Second) {
+			if current := signature(); current != last {
+				last = current
+				onChange()
+			}
+		}
+	}()
+}
+
+// Creates a signature from the size and modified time of files.
+func filesSignature(paths ...string) string {
+	signature := ""
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil {
+			signature += fmt.Sprintf("%s:%d:%d;", path, info.Size(), info.ModTime().UnixNano())
+		}
+	}
+	return signature
+}
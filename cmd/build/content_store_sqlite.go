@@ -0,0 +1,125 @@
+package build
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"plenti/readers"
+	"regexp"
+
+	// Registers the "sqlite3" driver, SQLite is compiled into plenti so nothing needs to be installed.
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Table and column names are added to queries, so only allow plain identifiers.
+var reSQLIdentifier = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// Store for rows of a SQLite database.
+type sqliteStore struct {
+	contentType string
+	options     readers.ContentStoreOptions
+	rows        map[string][]byte
+}
+
+func (s *sqliteStore) Types() ([]string, error) {
+	return []string{s.contentType}, nil
+}
+
+func (s *sqliteStore) Nodes(contentType string) ([]ContentNode, error) {
+	if s.options.Path == "" {
+		return nil, fmt.Errorf("The sqlite content store for type '%s' needs a path to the database", s.contentType)
+	}
+	key := s.options.Key
+	if key == "" {
+		key = "id"
+	}
+	query := s.options.Query
+	if query == "" {
+		table := s.options.Table
+		if table == "" {
+			table = s.contentType
+		}
+		if !reSQLIdentifier.MatchString(table) {
+			return nil, fmt.Errorf("Invalid table name '%s' for type '%s'", table, s.contentType)
+		}
+		query = "SELECT * FROM \"" + table + "\";"
+	}
+
+	Log("Querying " + s.options.Path + " for '" + s.contentType + "' content")
+
+	// Open read only so a typo in the path doesn't create an empty database.
+	db, err := sql.Open("sqlite3", "file:"+(&url.URL{Path: s.options.Path}).EscapedPath()+"?mode=ro")
+	if err != nil {
+		return nil, fmt.Errorf("Could not open %s for type '%s': %w", s.options.Path, s.contentType, err)
+	}
+	defer db.Close()
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("Could not query %s for type '%s': %w", s.options.Path, s.contentType, err)
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := []ContentNode{}
+	s.rows = map[string][]byte{}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err = rows.Scan(pointers...); err != nil {
+			return nil, fmt.Errorf("Could not read row for type '%s': %w", s.contentType, err)
+		}
+		row := map[string]interface{}{}
+		for i, column := range columns {
+			// Text comes back as bytes, which would otherwise be encoded as base64.
+			if bytesValue, ok := values[i].([]byte); ok {
+				values[i] = string(bytesValue)
+			}
+			row[column] = values[i]
+		}
+		keyValue, ok := row[key]
+		if !ok {
+			return nil, fmt.Errorf("Results for type '%s' are missing the '%s' key column", s.contentType, key)
+		}
+		// Keys can be numbers or strings, either way they become the filename.
+		name := fmt.Sprintf("%v", keyValue)
+		// Columns are marshalled with sorted keys so builds are reproducible.
+		fields, err := json.MarshalIndent(row, "", "\t")
+		if err != nil {
+			return nil, err
+		}
+		node := ContentNode{
+			Type:     s.contentType,
+			Path:     "/" + s.contentType + "/" + name + ".json",
+			Filename: name + ".json",
+		}
+		s.rows[node.Path] = fields
+		nodes = append(nodes, node)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("Could not query %s for type '%s': %w", s.options.Path, s.contentType, err)
+	}
+	return nodes, nil
+}
+
+func (s *sqliteStore) Fields(node ContentNode) ([]byte, error) {
+	fields, ok := s.rows[node.Path]
+	if !ok {
+		return nil, fmt.Errorf("No row found for %s", node.Path)
+	}
+	return fields, nil
+}
+
+// Watch polls the database files since changes can come from another program at any time.
+func (s *sqliteStore) Watch(onChange func()) error {
+	pollChanges(func() string {
+		return filesSignature(s.options.Path, s.options.Path+"-wal")
+	}, onChange)
+	return nil
+}
@@ -0,0 +1,242 @@
+package build
+
+import (
+	"database/sql"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"plenti/readers"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type walkedNode struct {
+	Type     string
+	Path     string
+	Filename string
+	Fields   interface{}
+}
+
+// How the data source walked "content/" before content stores existed.
+func legacyContentWalk(t *testing.T, tempBuildDir string) []walkedNode {
+	t.Helper()
+	nodes := []walkedNode{}
+	err := filepath.Walk(tempBuildDir+"content", func(path string, info os.FileInfo, err error) error {
+		if info.IsDir() {
+			return nil
+		}
+		// Split the path below "content/" rather than at a fixed index, which only worked for a one folder tempBuildDir.
+		parts := strings.Split(strings.TrimPrefix(path, tempBuildDir), "/")
+		contentType := parts[1]
+		fileName := parts[len(parts)-1]
+		if fileName[:1] == "_" || fileName[:1] == "." {
+			return nil
+		}
+		fileContentBytes, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		nodes = append(nodes, walkedNode{
+			Type:     strings.TrimSuffix(contentType, filepath.Ext(contentType)),
+			Path:     strings.TrimPrefix(path, tempBuildDir+"content"),
+			Filename: fileName,
+			Fields:   decodeFields(t, fileContentBytes),
+		})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return nodes
+}
+
+func storeNodes(t *testing.T, siteConfig readers.SiteConfig, tempBuildDir string) []walkedNode {
+	t.Helper()
+	stores, err := ContentStores(siteConfig, tempBuildDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodes := []walkedNode{}
+	err = eachContentNode(stores, func(node ContentNode, fields []byte) error {
+		nodes = append(nodes, walkedNode{Type: node.Type, Path: node.Path, Filename: node.Filename, Fields: decodeFields(t, fields)})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return nodes
+}
+
+func decodeFields(t *testing.T, fields []byte) interface{} {
+	t.Helper()
+	var decoded interface{}
+	if err := json.Unmarshal(fields, &decoded); err != nil {
+		t.Fatalf("fields aren't valid json: %v\n%s", err, fields)
+	}
+	return decoded
+}
+
+// Content laid out like the starter site, including files the data source skips.
+var storeTestContent = map[string]string{
+	"content/index.json":              `{"title": "Home", "intro": ["one", "two"]}`,
+	"content/404.json":                `{"title": "Not found"}`,
+	"content/blog/_blueprint.json":    `{"title": "text"}`,
+	"content/blog/post1.json":         `{"title": "Post 1", "tags": {"a": 1}}`,
+	"content/blog/post2.json":         `{"title": "Post 2"}`,
+	"content/blog/.draft.json":        `{"title": "Draft"}`,
+	"content/blog/2020/index.json":    `{"title": "2020"}`,
+	"content/pages/about.json":        `{"title": "About", "count": 3, "price": 1.5, "draft": false, "empty": null}`,
+	"content/authors/jim.json":        `{"name": "Jim"}`,
+	"content/authors/_blueprint.json": `{"name": "text"}`,
+}
+
+func TestFsStoreMatchesContentWalk(t *testing.T) {
+	for _, tempBuildDir := range []string{"", "themes/.build/"} {
+		t.Run("temp build dir "+tempBuildDir, func(t *testing.T) {
+			chdirTemp(t)
+			files := map[string]string{}
+			for path, content := range storeTestContent {
+				files[tempBuildDir+path] = content
+			}
+			writeFiles(t, files)
+
+			want := legacyContentWalk(t, tempBuildDir)
+			got := storeNodes(t, readers.SiteConfig{}, tempBuildDir)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("stores yielded\n%+v\nwant\n%+v", got, want)
+			}
+			// Assigning types to json explicitly changes nothing.
+			got = storeNodes(t, readers.SiteConfig{ContentStores: map[string]readers.ContentStoreOptions{
+				"blog":  {Store: "json"},
+				"index": {},
+			}}, tempBuildDir)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("stores with explicit json types yielded\n%+v\nwant\n%+v", got, want)
+			}
+		})
+	}
+}
+
+func TestYamlStoreMatchesJSON(t *testing.T) {
+	chdirTemp(t)
+	writeFiles(t, map[string]string{
+		"content/authors/jim.json":      `{"name": "Jim", "age": 3, "ratio": 0.5, "admin": true, "none": null, "roles": ["a", "b"], "links": {"site": "https://example.com", "2": "two"}}`,
+		"content/authors/team/ann.json": `{"name": "Ann"}`,
+		"yaml/jim.yaml":                 "name: Jim\nage: 3\nratio: 0.5\nadmin: true\nnone: null\nroles:\n  - a\n  - b\nlinks:\n  site: https://example.com\n  2: two\n",
+		"yaml/team/ann.yml":             "name: Ann\n",
+		"yaml/_ignored.yaml":            "name: Ignored\n",
+		"yaml/notes.txt":                "not content",
+	})
+
+	want := storeNodes(t, readers.SiteConfig{}, "")
+	if len(want) != 2 {
+		t.Fatalf("json content has %d nodes, want 2", len(want))
+	}
+	if err := os.RemoveAll("content/authors"); err != nil {
+		t.Fatal(err)
+	}
+	got := storeNodes(t, readers.SiteConfig{ContentStores: map[string]readers.ContentStoreOptions{
+		"authors": {Store: "yaml", Path: "yaml"},
+	}}, "")
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("yaml store yielded\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestMixedContentStores(t *testing.T) {
+	chdirTemp(t)
+	files := map[string]string{
+		"yaml/jim.yaml": "name: Jim from yaml\n",
+	}
+	for path, content := range storeTestContent {
+		files[path] = content
+	}
+	writeFiles(t, files)
+
+	db, err := sql.Open("sqlite3", "shop.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`CREATE TABLE products (sku TEXT, title TEXT, price REAL, stock INTEGER, notes TEXT);
+		INSERT INTO products VALUES ('shoe', 'Shoe', 9.5, 3, NULL), ('hat', 'Hat', 4, 0, 'Wool');`)
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	siteConfig := readers.SiteConfig{ContentStores: map[string]readers.ContentStoreOptions{
+		"authors":  {Store: "yaml", Path: "yaml"},
+		"products": {Store: "sqlite", Path: "shop.db", Key: "sku"},
+		"pages":    {Store: "sqlite", Path: "shop.db", Query: "SELECT sku AS id, title FROM products WHERE stock > 0"},
+		"blog":     {Store: "json"},
+	}}
+	got := storeNodes(t, siteConfig, "")
+
+	byPath := map[string]walkedNode{}
+	for _, node := range got {
+		if _, exists := byPath[node.Path]; exists {
+			t.Errorf("%s was read from more than one store", node.Path)
+		}
+		byPath[node.Path] = node
+	}
+	expected := map[string]interface{}{
+		"/index.json":           map[string]interface{}{"title": "Home", "intro": []interface{}{"one", "two"}},
+		"/404.json":             map[string]interface{}{"title": "Not found"},
+		"/blog/post1.json":      map[string]interface{}{"title": "Post 1", "tags": map[string]interface{}{"a": 1.0}},
+		"/blog/post2.json":      map[string]interface{}{"title": "Post 2"},
+		"/blog/2020/index.json": map[string]interface{}{"title": "2020"},
+		"/authors/jim.json":     map[string]interface{}{"name": "Jim from yaml"},
+		"/products/shoe.json":   map[string]interface{}{"sku": "shoe", "title": "Shoe", "price": 9.5, "stock": 3.0, "notes": nil},
+		"/products/hat.json":    map[string]interface{}{"sku": "hat", "title": "Hat", "price": 4.0, "stock": 0.0, "notes": "Wool"},
+		"/pages/shoe.json":      map[string]interface{}{"id": "shoe", "title": "Shoe"},
+	}
+	if len(byPath) != len(expected) {
+		t.Errorf("got %d nodes, want %d: %+v", len(byPath), len(expected), got)
+	}
+	for path, fields := range expected {
+		node, ok := byPath[path]
+		if !ok {
+			t.Errorf("missing %s", path)
+			continue
+		}
+		if !reflect.DeepEqual(node.Fields, fields) {
+			t.Errorf("%s fields = %v, want %v", path, node.Fields, fields)
+		}
+		if wantType := strings.Split(strings.TrimSuffix(path, ".json"), "/")[1]; node.Type != wantType {
+			t.Errorf("%s has type %s, want %s", path, node.Type, wantType)
+		}
+	}
+
+	if types := ExternalContentTypes(siteConfig); !reflect.DeepEqual(types, []string{"authors", "pages", "products"}) {
+		t.Errorf("external types = %v", types)
+	}
+}
+
+func TestContentStoreErrors(t *testing.T) {
+	chdirTemp(t)
+	writeFiles(t, map[string]string{"content/index.json": `{}`})
+
+	tests := map[string]readers.ContentStoreOptions{
+		"unknown store":       {Store: "csv"},
+		"yaml without path":   {Store: "yaml"},
+		"sqlite without path": {Store: "sqlite"},
+		"missing database":    {Store: "sqlite", Path: "missing.db"},
+		"invalid table":       {Store: "sqlite", Path: "missing.db", Table: "a; DROP TABLE b"},
+	}
+	for name, options := range tests {
+		t.Run(name, func(t *testing.T) {
+			stores, err := ContentStores(readers.SiteConfig{ContentStores: map[string]readers.ContentStoreOptions{"things": options}}, "")
+			if err == nil {
+				err = eachContentNode(stores, func(ContentNode, []byte) error { return nil })
+			}
+			if err == nil {
+				t.Error("no error")
+			}
+		})
+	}
+	if _, err := os.Stat("missing.db"); !os.IsNotExist(err) {
+		t.Error("reading a missing database created it")
+	}
+}
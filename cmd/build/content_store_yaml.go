@@ -0,0 +1,103 @@
+package build
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Store for a directory of YAML files, where every file is a node of the type.
+type yamlStore struct {
+	contentType string
+	dir         string
+}
+
+func (s *yamlStore) Types() ([]string, error) {
+	return []string{s.contentType}, nil
+}
+
+func (s *yamlStore) Nodes(contentType string) ([]ContentNode, error) {
+	if s.dir == "" {
+		return nil, fmt.Errorf("The yaml content store for type '%s' needs a path to a directory", s.contentType)
+	}
+	nodes := []ContentNode{}
+	err := filepath.Walk(s.dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		ext := filepath.Ext(path)
+		if info.IsDir() || (ext != ".yaml" && ext != ".yml") || info.Name()[:1] == "_" || info.Name()[:1] == "." {
+			return nil
+		}
+		// Nested folders are kept in the path just like in "content/".
+		relativePath := strings.TrimSuffix(filepath.ToSlash(strings.TrimPrefix(path, s.dir)), ext)
+		nodes = append(nodes, ContentNode{
+			Type:     s.contentType,
+			Path:     "/" + s.contentType + "/" + strings.TrimPrefix(relativePath, "/") + ".json",
+			Filename: strings.TrimSuffix(info.Name(), ext) + ".json",
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Could not get yaml content for type '%s': %w", s.contentType, err)
+	}
+	return nodes, nil
+}
+
+func (s *yamlStore) Fields(node ContentNode) ([]byte, error) {
+	basePath := s.dir + "/" + strings.TrimSuffix(strings.TrimPrefix(node.Path, "/"+s.contentType+"/"), ".json")
+	yamlBytes, err := ioutil.ReadFile(basePath + ".yaml")
+	if os.IsNotExist(err) {
+		yamlBytes, err = ioutil.ReadFile(basePath + ".yml")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Could not read yaml content file: %w", err)
+	}
+	var fields interface{}
+	if err = yaml.Unmarshal(yamlBytes, &fields); err != nil {
+		return nil, fmt.Errorf("Could not parse yaml content file %s: %w", basePath, err)
+	}
+	fieldsJSON, err := json.MarshalIndent(jsonCompatible(fields), "", "\t")
+	if err != nil {
+		return nil, fmt.Errorf("Could not convert yaml content file %s to json: %w", basePath, err)
+	}
+	return fieldsJSON, nil
+}
+
+// Watch polls the YAML directory since it can live outside the folders fsnotify watches.
+func (s *yamlStore) Watch(onChange func()) error {
+	pollChanges(func() string {
+		paths := []string{}
+		filepath.Walk(s.dir, func(path string, info os.FileInfo, err error) error {
+			if err == nil {
+				paths = append(paths, path)
+			}
+			return nil
+		})
+		return filesSignature(paths...)
+	}, onChange)
+	return nil
+}
+
+// YAML maps can have keys of any type, convert them to string keys so they can be encoded as JSON.
+func jsonCompatible(value interface{}) interface{} {
+	switch typedValue := value.(type) {
+	case map[interface{}]interface{}:
+		converted := map[string]interface{}{}
+		for key, nestedValue := range typedValue {
+			converted[fmt.Sprintf("%v", key)] = jsonCompatible(nestedValue)
+		}
+		return converted
+	case []interface{}:
+		for i, nestedValue := range typedValue {
+			typedValue[i] = jsonCompatible(nestedValue)
+		}
+		return typedValue
+	}
+	return value
+}
@@ -22,10 +22,19 @@ type content struct {
 	contentPagerDest string
 	contentPagerPath string
 	contentPagerNums []string
+	contentSource    string
+	contentEditable  []editableField
+	// Where the content lives in its store, e.g. "/blog/post1.json" (identifies it between builds).
+	contentKey string
 }
 
 // DataSource builds json list from "content/" directory.
 func DataSource(buildPath string, siteConfig readers.SiteConfig, tempBuildDir string) error {
+	return dataSource(buildPath, siteConfig, tempBuildDir, nil)
+}
+
+// Builds the data source, only rendering content that isn't in previousNodes or has changed since (nil renders everything).
+func dataSource(buildPath string, siteConfig readers.SiteConfig, tempBuildDir string, previousNodes map[string]RenderedNode) error {
 
 	defer Benchmark(time.Now(), "Creating data_source")
 
@@ -36,6 +45,10 @@ func DataSource(buildPath string, siteConfig readers.SiteConfig, tempBuildDir st
 		return err
 	}
 
+	// Keep track of the pages written so incremental builds can remove the ones that no longer exist.
+	renderedPages = []string{}
+	renderedNodes = map[string]RenderedNode{}
+
 	// Set up counter for logging output.
 	contentFileCounter := 0
 	// Start the string that will be used for allContent object.
@@ -50,148 +63,169 @@ func DataSource(buildPath string, siteConfig readers.SiteConfig, tempBuildDir st
 		return err
 	}
 
-	// Go through all sub directories in "content/" folder.
-	contentFilesErr := filepath.Walk(tempBuildDir+"content", func(path string, info os.FileInfo, err error) error {
-		if !info.IsDir() {
-			// Get individual path arguments.
-			parts := strings.Split(path, "/")
-			contentType := parts[1]
-			if tempBuildDir != "" {
-				contentType = parts[2]
-			}
-			fileName := parts[len(parts)-1]
-
-			// Don't add _blueprint.json or other special named files starting with underscores.
-			if fileName[:1] != "_" && fileName[:1] != "." {
+	// Get the stores content is read from (the "content/" folder unless plenti.json assigns a type elsewhere).
+	stores, err := ContentStores(siteConfig, tempBuildDir)
+	if err != nil {
+		return err
+	}
 
-				// Get the contents of the file.
-				fileContentBytes, err := ioutil.ReadFile(path)
-				if err != nil {
-					fmt.Printf("Could not read content file: %s\n", err)
-					return err
-				}
-				fileContentStr := string(fileContentBytes)
-
-				// Remove the "content" folder from path.
-				path = strings.TrimPrefix(path, tempBuildDir+"content")
-
-				// Check for index file at any level.
-				if fileName == "index.json" {
-					// Remove entire filename from path.
-					path = strings.TrimSuffix(path, fileName)
-				} else {
-					// Remove file extension only from path for files other than index.json.
-					path = strings.TrimSuffix(path, filepath.Ext(path))
-				}
+	// Go through every node of every type in the content stores.
+	contentFilesErr := eachContentNode(stores, func(node ContentNode, fileContentBytes []byte) error {
+		contentType := node.Type
+		fileName := node.Filename
+		fileContentStr := string(fileContentBytes)
+
+		// Keep the location of the file relative to "content/" so edits can be mapped back to it.
+		sourcePath := node.Source
+
+		// Path within the store, e.g. "/blog/post1.json".
+		path := node.Path
+
+		// Check for index file at any level.
+		if strings.TrimSuffix(fileName, filepath.Ext(fileName)) == "index" {
+			// Remove entire filename from path.
+			path = strings.TrimSuffix(path, fileName)
+		} else {
+			// Remove file extension only from path for files other than index.json.
+			path = strings.TrimSuffix(path, filepath.Ext(path))
+		}
 
-				// Remove the extension (if it exists) from single types since the filename = the type name.
-				contentType = strings.TrimSuffix(contentType, filepath.Ext(contentType))
-
-				// Get field key/values from content source.
-				typeFields := readers.GetTypeFields(fileContentBytes)
-				// Setup regex to find field name.
-				reField := regexp.MustCompile(`:field\((.*?)\)`)
-				// Check for path overrides from plenti.json config file.
-				for configContentType, slug := range siteConfig.Types {
-					if configContentType == contentType {
-						// Replace :filename.
-						slug = strings.Replace(slug, ":filename", strings.TrimSuffix(fileName, filepath.Ext(fileName)), -1)
-
-						// Replace :field().
-						fieldReplacements := reField.FindAllStringSubmatch(slug, -1)
-						// Loop through all :field() replacements found in config file.
-						for _, replacement := range fieldReplacements {
-							// Loop through all top level keys found in content source file.
-							for field, fieldValue := range typeFields.Fields {
-								// Check if field name in the replacement pattern is found in data source.
-								if replacement[1] == field {
-									// Use the field value in the path.
-									slug = strings.ReplaceAll(slug, replacement[0], fieldValue)
-								}
-							}
+		// Get field key/values from content source.
+		typeFields := readers.GetTypeFields(fileContentBytes)
+		// Setup regex to find field name.
+		reField := regexp.MustCompile(`:field\((.*?)\)`)
+		// Check for path overrides from plenti.json config file.
+		for configContentType, slug := range siteConfig.Types {
+			if configContentType == contentType {
+				// Replace :filename.
+				slug = strings.Replace(slug, ":filename", strings.TrimSuffix(fileName, filepath.Ext(fileName)), -1)
+
+				// Replace :field().
+				fieldReplacements := reField.FindAllStringSubmatch(slug, -1)
+				// Loop through all :field() replacements found in config file.
+				for _, replacement := range fieldReplacements {
+					// Loop through all top level keys found in content source file.
+					for field, fieldValue := range typeFields.Fields {
+						// Check if field name in the replacement pattern is found in data source.
+						if replacement[1] == field {
+							// Use the field value in the path.
+							slug = strings.ReplaceAll(slug, replacement[0], fieldValue)
 						}
-						path = slug
 					}
 				}
+				path = slug
+			}
+		}
 
-				// Setup regex to find pagination and a leading forward slash.
-				rePaginate := regexp.MustCompile(`/:paginate\((.*?)\)`)
-				// Initialize vars for path with replacement patterns still intact.
-				var pagerPath string
-				var pagerDestPath string
-				// If there is a /:paginate() replacement found.
-				if rePaginate.MatchString(path) {
-					// Save path before slugifying to preserve pagination.
-					pagerPath = path
-					// Get Destination path before slugifying to preserve pagination.
-					pagerDestPath = buildPath + path + "/index.html"
-					// Remove /:pagination()
-					path = rePaginate.ReplaceAllString(path, "")
-					// If paginating the homepage, the forward slash shouldn't be removed.
-					if path == "" {
-						// Add the forward slash back for the index page.
-						path = "/"
-					}
-				}
+		// Setup regex to find pagination and a leading forward slash.
+		rePaginate := regexp.MustCompile(`/:paginate\((.*?)\)`)
+		// Initialize vars for path with replacement patterns still intact.
+		var pagerPath string
+		var pagerDestPath string
+		// If there is a /:paginate() replacement found.
+		if rePaginate.MatchString(path) {
+			// Save path before slugifying to preserve pagination.
+			pagerPath = path
+			// Get Destination path before slugifying to preserve pagination.
+			pagerDestPath = buildPath + path + "/index.html"
+			// Remove /:pagination()
+			path = rePaginate.ReplaceAllString(path, "")
+			// If paginating the homepage, the forward slash shouldn't be removed.
+			if path == "" {
+				// Add the forward slash back for the index page.
+				path = "/"
+			}
+		}
 
-				// Create regex for allowed characters when slugifying path.
-				reSlugify := regexp.MustCompile("[^a-z0-9/]+")
-				// Slugify output using reSlugify regex defined above.
-				path = strings.Trim(reSlugify.ReplaceAllString(strings.ToLower(path), "-"), "-")
+		// Create regex for allowed characters when slugifying path.
+		reSlugify := regexp.MustCompile("[^a-z0-9/]+")
+		// Slugify output using reSlugify regex defined above.
+		path = strings.Trim(reSlugify.ReplaceAllString(strings.ToLower(path), "-"), "-")
 
-				// Remove trailing slash, unless it's the homepage.
-				if path != "/" && path[len(path)-1:] == "/" {
-					path = strings.TrimSuffix(path, "/")
-				}
+		// Remove trailing slash, unless it's the homepage.
+		if path != "/" && path[len(path)-1:] == "/" {
+			path = strings.TrimSuffix(path, "/")
+		}
 
-				destPath := buildPath + path + "/index.html"
+		destPath := buildPath + path + "/index.html"
 
-				contentDetailsStr := "{\n" +
-					"\"pager\": 1,\n" +
-					"\"path\": \"" + path + "\",\n" +
-					"\"type\": \"" + contentType + "\",\n" +
-					"\"filename\": \"" + fileName + "\",\n" +
-					"\"fields\": " + fileContentStr + "\n}"
+		contentDetailsStr := "{\n" +
+			"\"pager\": 1,\n" +
+			"\"path\": \"" + path + "\",\n" +
+			"\"type\": \"" + contentType + "\",\n" +
+			"\"filename\": \"" + fileName + "\",\n" +
+			editSource(sourcePath) +
+			"\"fields\": " + fileContentStr + "\n}"
 
-				// Write to the content.js client data source file.
-				if err = writeContentJS(contentJSPath, contentDetailsStr+","); err != nil {
-					return err
-				}
+		// Write to the content.js client data source file.
+		if err = writeContentJS(contentJSPath, contentDetailsStr+","); err != nil {
+			return err
+		}
 
-				// Remove newlines, tabs, and extra space.
-				encodedContentDetails := encodeString(contentDetailsStr)
-				// Add info for being referenced in allContent object.
-				allContentStr = allContentStr + encodedContentDetails + ","
-
-				content := content{
-					contentType:      contentType,
-					contentPath:      path,
-					contentDest:      destPath,
-					contentDetails:   encodedContentDetails,
-					contentFilename:  fileName,
-					contentFields:    encodeString(fileContentStr),
-					contentPagerDest: pagerDestPath,
-					contentPagerPath: pagerPath,
-				}
-				allContent = append(allContent, content)
+		// Remove newlines, tabs, and extra space.
+		encodedContentDetails := encodeString(contentDetailsStr)
+		// Add info for being referenced in allContent object.
+		allContentStr = allContentStr + encodedContentDetails + ","
+
+		content := content{
+			contentType:      contentType,
+			contentPath:      path,
+			contentDest:      destPath,
+			contentDetails:   encodedContentDetails,
+			contentFilename:  fileName,
+			contentFields:    encodeString(fileContentStr),
+			contentPagerDest: pagerDestPath,
+			contentPagerPath: pagerPath,
+			contentSource:    sourcePath,
+			contentEditable:  editableFields(tempBuildDir+"content", sourcePath, fileContentBytes),
+			contentKey:       node.Path,
+		}
+		allContent = append(allContent, content)
 
-				// Increment counter for logging purposes.
-				contentFileCounter++
+		// Increment counter for logging purposes.
+		contentFileCounter++
 
-			}
-		}
 		return nil
 	})
 	if contentFilesErr != nil {
-		return fmt.Errorf("Could not get layout file: %w", contentFilesErr)
+		return fmt.Errorf("Could not get content: %w", contentFilesErr)
 
 	}
 
+	// Add success and failure routes for forms that don't have their own content.
+	existingPaths := map[string]bool{}
+	for _, currentContent := range allContent {
+		existingPaths[currentContent.contentPath] = true
+	}
+	for _, formResult := range formResults(siteConfig, buildPath, existingPaths) {
+		if err = writeContentJS(contentJSPath, formResult.contentDetails+","); err != nil {
+			return err
+		}
+		allContentStr = allContentStr + formResult.contentDetails + ","
+		allContent = append(allContent, formResult)
+	}
+
 	// End the string that will be used in allContent object.
 	allContentStr = strings.TrimSuffix(allContentStr, ",") + "]"
 
+	// Work out which content has to be rendered again when the last build is being updated.
+	render := nodesToRender(allContent, buildPath, previousNodes)
+	renderedCounter := 0
+
 	for _, currentContent := range allContent {
 
+		node := RenderedNode{Checksum: nodeChecksum(currentContent)}
+		if previousNodes != nil && !render[currentContent.contentKey] {
+			// Nothing the pages are rendered from changed, so keep them from the last build.
+			node.Pages = previousNodes[currentContent.contentKey].Pages
+			for _, page := range node.Pages {
+				renderedPages = append(renderedPages, buildPath+page)
+			}
+			renderedNodes[currentContent.contentKey] = node
+			continue
+		}
+		firstPage := len(renderedPages)
+
 		if err = createProps(currentContent, allContentStr); err != nil {
 			return err
 		}
@@ -215,6 +249,19 @@ func DataSource(buildPath string, siteConfig readers.SiteConfig, tempBuildDir st
 
 		}
 
+		for _, page := range renderedPages[firstPage:] {
+			node.Pages = append(node.Pages, relativePage(buildPath, page))
+		}
+		renderedNodes[currentContent.contentKey] = node
+		renderedCounter++
+
+	}
+
+	if previousNodes != nil {
+		if err = checkPageConflicts(render); err != nil {
+			return err
+		}
+		Log(fmt.Sprintf("Rendered %d of %d content nodes, the rest were kept from the last build", renderedCounter, len(allContent)))
 	}
 
 	Log("Number of content files used: " + fmt.Sprint(contentFileCounter))
@@ -231,6 +278,12 @@ func createProps(currentContent content, allContentStr string) error {
 		return fmt.Errorf("Could not create props: %w", err)
 
 	}
+	// Mark where editable fields are rendered when serving with --edit.
+	if len(currentContent.contentEditable) > 0 {
+		if _, err = SSRctx.RunScript(editMarkFields(currentContent.contentEditable), "create_ssr"); err != nil {
+			return fmt.Errorf("Could not mark editable fields: %w", err)
+		}
+	}
 	// Render the HTML with props needed for the current content.
 	_, err = SSRctx.RunScript("var { html, css: staticCss} = layout_global_html_svelte.render(props);", "create_ssr")
 	if err != nil {
@@ -249,6 +302,8 @@ func createHTML(currentContent content) error {
 	}
 	// Get the string value of the static HTML.
 	renderedHTMLStr := renderedHTML.String()
+	// Load the editing bridge when serving with --edit.
+	renderedHTMLStr = editScript(editMarkers(renderedHTMLStr, currentContent.contentEditable))
 	// Convert the string to byte array that can be written to file system.
 	htmlBytes := []byte(renderedHTMLStr)
 	// Create any folders need to write file.
@@ -260,6 +315,7 @@ func createHTML(currentContent content) error {
 	if err != nil {
 		return fmt.Errorf("unable to write SSR file: %w", err)
 	}
+	renderedPages = append(renderedPages, currentContent.contentDest)
 	return nil
 }
 
@@ -334,6 +390,7 @@ func incrementPager(paginationVars []string, currentContent content, contentJSPa
 			"\"path\": \"" + newContent.contentPath + "\",\n" +
 			"\"type\": \"" + newContent.contentType + "\",\n" +
 			"\"filename\": \"" + newContent.contentFilename + "\",\n" +
+			editSource(newContent.contentSource) +
 			"\"fields\": " + newContent.contentFields + "\n}"
 
 		// Add paginated entries to content.js file.
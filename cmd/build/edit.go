@@ -0,0 +1,293 @@
+package build
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Create global var since cmd.EditFlag is a circular dependency.
+var editFlag bool
+
+// CheckEditFlag sets global var if --edit flag is passed to the serve command.
+func CheckEditFlag(flag bool) {
+	// If --edit flag is passed by user, this will be set to true.
+	editFlag = flag
+}
+
+// EditableFieldTypes are the field types from _blueprint.json that can be edited in the browser.
+var EditableFieldTypes = []string{
+	"text",
+	"string",
+	"markdown",
+}
+
+// A field of a content node that can be edited in the browser.
+type editableField struct {
+	Name string
+	// Markdown is usually rendered as blocks, so its markers go on lines of their own.
+	Markdown bool
+}
+
+// Characters from the Unicode private use area that wrap a field's value during SSR, e.g. "\uE0000\uE001" + value + "\uE0020\uE001".
+// They pass through escaping and markdown untouched, and are removed again before the HTML is written.
+const (
+	editMarkerStart = "\uE000"
+	editMarkerEnd   = "\uE002"
+	editMarkerClose = "\uE001"
+)
+
+// Field markers, including the paragraph markdown puts them in or the blank lines they were added with.
+var reEditMarker = regexp.MustCompile(`<p>[\x{E000}\x{E002}][0-9]+\x{E001}</p>\n?|\x{E000}[0-9]+\x{E001}(?:\n\n)?|(?:\n\n)?\x{E002}[0-9]+\x{E001}`)
+
+// The kind and field index within a marker.
+var reEditMarkerIndex = regexp.MustCompile(`([\x{E000}\x{E002}])([0-9]+)`)
+
+// Start and end tags of elements (comments are matched so tags inside them are ignored).
+var reEditTag = regexp.MustCompile(`<!--[\s\S]*?-->|<(/?)([a-zA-Z][a-zA-Z0-9-]*)(?:[^>"']|"[^"]*"|'[^']*')*>`)
+
+// Elements that never have children.
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true,
+	"input": true, "link": true, "meta": true, "param": true, "source": true, "track": true, "wbr": true,
+}
+
+// Adds the location of the content file to the node so the editing bridge knows where to save changes.
+func editSource(sourcePath string) string {
+	if !editFlag || sourcePath == "" {
+		return ""
+	}
+	// Quotes and backslashes in filenames would otherwise break the node's JSON.
+	source, _ := json.Marshal(filepath.ToSlash(sourcePath))
+	return "\"source\": " + string(source) + ",\n"
+}
+
+// Gets the fields of a content file that can be edited in the browser (none when not serving with --edit).
+func editableFields(contentDir string, source string, fileBytes []byte) []editableField {
+	if !editFlag || source == "" {
+		return nil
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(fileBytes, &fields); err != nil {
+		return nil
+	}
+	blueprint, err := readBlueprint(contentDir, source)
+	if err != nil {
+		return nil
+	}
+	editable := []editableField{}
+	for field := range fields {
+		if checkFieldType(fields, blueprint, field) == nil {
+			editable = append(editable, editableField{Name: field, Markdown: blueprint[field] == "markdown"})
+		}
+	}
+	sort.Slice(editable, func(i, j int) bool { return editable[i].Name < editable[j].Name })
+	return editable
+}
+
+// CheckEditableField checks the field is a string in the content file and an editable type in the type's _blueprint.json.
+func CheckEditableField(contentDir string, source string, fileBytes []byte, field string) error {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(fileBytes, &fields); err != nil {
+		return fmt.Errorf("Content file is not valid JSON: %w", err)
+	}
+	blueprint, err := readBlueprint(contentDir, source)
+	if err != nil {
+		return err
+	}
+	return checkFieldType(fields, blueprint, field)
+}
+
+// Reads the _blueprint.json next to a content file, which every edit is validated against.
+func readBlueprint(contentDir string, source string) (map[string]interface{}, error) {
+	blueprintBytes, err := ioutil.ReadFile(filepath.Join(contentDir, filepath.Dir(filepath.FromSlash(source)), "_blueprint.json"))
+	if err != nil || len(bytes.TrimSpace(blueprintBytes)) == 0 {
+		// Single types like content/index.json don't have a blueprint, so there is nothing to check edits against.
+		return nil, fmt.Errorf("content/%s has no _blueprint.json to check edits against, only types with a blueprint can be edited in the browser", source)
+	}
+	var blueprint map[string]interface{}
+	if err = json.Unmarshal(blueprintBytes, &blueprint); err != nil {
+		return nil, fmt.Errorf("Could not read _blueprint.json for content/%s: %w", source, err)
+	}
+	return blueprint, nil
+}
+
+func checkFieldType(fields map[string]interface{}, blueprint map[string]interface{}, field string) error {
+	value, ok := fields[field]
+	if !ok {
+		return fmt.Errorf("Field \"%s\" does not exist in the content file", field)
+	}
+	if _, isString := value.(string); !isString {
+		return fmt.Errorf("Field \"%s\" is not a string and can't be edited in the browser", field)
+	}
+	fieldType, _ := blueprint[field].(string)
+	for _, editableFieldType := range EditableFieldTypes {
+		if fieldType == editableFieldType {
+			return nil
+		}
+	}
+	if _, ok = blueprint[field]; !ok {
+		return errors.New("Field \"" + field + "\" isn't in _blueprint.json, only fields with a type can be edited")
+	}
+	return fmt.Errorf("Field \"%s\" has type \"%v\" in _blueprint.json, only %s fields can be edited", field, blueprint[field], strings.Join(EditableFieldTypes, ", "))
+}
+
+// Wraps the editable fields of the props being rendered in markers.
+func editMarkFields(fields []editableField) string {
+	script := ""
+	for i, field := range fields {
+		name, _ := json.Marshal(field.Name)
+		start := strconv.Quote(editMarkerStart + strconv.Itoa(i) + editMarkerClose)
+		end := strconv.Quote(editMarkerEnd + strconv.Itoa(i) + editMarkerClose)
+		if field.Markdown {
+			start = strconv.Quote(editMarkerStart + strconv.Itoa(i) + editMarkerClose + "\n\n")
+			end = strconv.Quote("\n\n" + editMarkerEnd + strconv.Itoa(i) + editMarkerClose)
+		}
+		script += "props.content.fields[" + string(name) + "] = " + start + " + props.content.fields[" + string(name) + "] + " + end + ";\n"
+	}
+	return script
+}
+
+// Turns the markers in rendered HTML into data-plenti-field attributes on the innermost element that holds all of a field's value.
+// Markers that can't be mapped to an element (in attributes, or when only part of the value is rendered) are just removed.
+func editMarkers(renderedHTML string, fields []editableField) string {
+	if len(fields) == 0 {
+		return renderedHTML
+	}
+
+	type openTag struct {
+		name string
+		// Where attributes can be added to the start tag.
+		insert int
+	}
+	stack := []openTag{}
+	// Open elements where each marker started, by field.
+	starts := map[int][][]openTag{}
+	// Attributes to add, by position.
+	attributes := map[int]string{}
+	marked := map[int]bool{}
+
+	tags := reEditTag.FindAllStringSubmatchIndex(renderedHTML, -1)
+	markers := reEditMarker.FindAllStringSubmatchIndex(renderedHTML, -1)
+	// End of the element whose text isn't html (e.g. script), where tags and markers are ignored.
+	rawEnd := 0
+
+	t, m := 0, 0
+	for t < len(tags) || m < len(markers) {
+		if m < len(markers) && (t == len(tags) || markers[m][0] <= tags[t][0]) {
+			marker := markers[m]
+			m++
+			// Skip the paragraph tags that belong to the marker.
+			for t < len(tags) && tags[t][0] < marker[1] {
+				t++
+			}
+			if marker[0] < rawEnd {
+				continue
+			}
+			parts := reEditMarkerIndex.FindStringSubmatch(renderedHTML[marker[0]:marker[1]])
+			index, _ := strconv.Atoi(parts[2])
+			if index >= len(fields) {
+				continue
+			}
+			if parts[1] == editMarkerStart {
+				starts[index] = append(starts[index], append([]openTag{}, stack...))
+				continue
+			}
+			if len(starts[index]) == 0 {
+				continue
+			}
+			startStack := starts[index][len(starts[index])-1]
+			starts[index] = starts[index][:len(starts[index])-1]
+			common := 0
+			for common < len(startStack) && common < len(stack) && startStack[common] == stack[common] {
+				common++
+			}
+			if common == 0 {
+				continue
+			}
+			element := stack[common-1]
+			if element.name == "html" || element.name == "head" || element.name == "body" || marked[element.insert] {
+				continue
+			}
+			marked[element.insert] = true
+			attributes[element.insert] = " data-plenti-field=\"" + html.EscapeString(fields[index].Name) + "\""
+			continue
+		}
+
+		tag := tags[t]
+		t++
+		// Markers inside the tag are in attribute values.
+		for m < len(markers) && markers[m][0] < tag[1] {
+			m++
+		}
+		if tag[0] < rawEnd || tag[4] < 0 {
+			// Comments and anything inside an element like script.
+			continue
+		}
+		name := strings.ToLower(renderedHTML[tag[4]:tag[5]])
+		if renderedHTML[tag[2]:tag[3]] == "/" {
+			for i := len(stack) - 1; i >= 0; i-- {
+				if stack[i].name == name {
+					stack = stack[:i]
+					break
+				}
+			}
+			continue
+		}
+		if voidElements[name] || strings.HasSuffix(renderedHTML[tag[0]:tag[1]], "/>") {
+			continue
+		}
+		if name == "script" || name == "style" || name == "textarea" {
+			if end := strings.Index(strings.ToLower(renderedHTML[tag[1]:]), "</"+name); end >= 0 {
+				rawEnd = tag[1] + end
+			}
+		}
+		stack = append(stack, openTag{name: name, insert: tag[1] - 1})
+	}
+
+	// Attributes are only added to start tags, which never overlap a marker.
+	positions := []int{}
+	for position := range attributes {
+		positions = append(positions, position)
+	}
+	sort.Ints(positions)
+	var result strings.Builder
+	last := 0
+	for _, marker := range markers {
+		for len(positions) > 0 && positions[0] < marker[0] {
+			result.WriteString(renderedHTML[last:positions[0]] + attributes[positions[0]])
+			last = positions[0]
+			positions = positions[1:]
+		}
+		result.WriteString(renderedHTML[last:marker[0]])
+		last = marker[1]
+	}
+	for _, position := range positions {
+		result.WriteString(renderedHTML[last:position] + attributes[position])
+		last = position
+	}
+	result.WriteString(renderedHTML[last:])
+	return result.String()
+}
+
+// Adds the editing bridge script to rendered HTML.
+func editScript(renderedHTML string) string {
+	if !editFlag {
+		return renderedHTML
+	}
+	// Hydration removes attributes it doesn't know about, so hold on to the marked elements before the app loads.
+	script := "<script>window.plentiEditFields = Array.from(document.querySelectorAll('[data-plenti-field]'), element => [element, element.getAttribute('data-plenti-field')]);</script>" +
+		"<script type=\"module\" src=\"/spa/ejected/edit.js\"></script>"
+	if strings.Contains(renderedHTML, "</body>") {
+		return strings.Replace(renderedHTML, "</body>", script+"</body>", 1)
+	}
+	return renderedHTML + script
+}
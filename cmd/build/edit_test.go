@@ -0,0 +1,143 @@
+package build
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Wraps a value the same way editMarkFields does in SSR.
+func marked(index string, value string, markdown bool) string {
+	if markdown {
+		return editMarkerStart + index + editMarkerClose + "\n\n" + value + "\n\n" + editMarkerEnd + index + editMarkerClose
+	}
+	return editMarkerStart + index + editMarkerClose + value + editMarkerEnd + index + editMarkerClose
+}
+
+func TestEditMarkers(t *testing.T) {
+	fields := []editableField{{Name: "title"}, {Name: "body", Markdown: true}}
+	tests := []struct {
+		name     string
+		rendered string
+		want     string
+	}{
+		{
+			name:     "text",
+			rendered: `<html><body><main><h1 class="title">` + marked("0", "Hello", false) + `</h1></main></body></html>`,
+			want:     `<html><body><main><h1 class="title" data-plenti-field="title">Hello</h1></main></body></html>`,
+		},
+		{
+			name:     "same text as another field",
+			rendered: `<body><h1>` + marked("0", "Same", false) + `</h1><p>Same</p></body>`,
+			want:     `<body><h1 data-plenti-field="title">Same</h1><p>Same</p></body>`,
+		},
+		{
+			name:     "rendered twice",
+			rendered: `<body><h1>` + marked("0", "Hi", false) + `</h1><footer><span>` + marked("0", "Hi", false) + `</span></footer></body>`,
+			want:     `<body><h1 data-plenti-field="title">Hi</h1><footer><span data-plenti-field="title">Hi</span></footer></body>`,
+		},
+		{
+			name:     "text between elements",
+			rendered: `<body><div>By <em>` + marked("0", "me", false) + `</em> on <time>today</time></div></body>`,
+			want:     `<body><div>By <em data-plenti-field="title">me</em> on <time>today</time></div></body>`,
+		},
+		{
+			// What a markdown library makes of the value, with the markers as paragraphs of their own.
+			name:     "markdown",
+			rendered: "<body><article>\n" + "<p>" + editMarkerStart + "1" + editMarkerClose + "</p>\n<h1>Hello</h1>\n<p>World</p>\n<p>" + editMarkerEnd + "1" + editMarkerClose + "</p>\n</article></body>",
+			want:     "<body><article data-plenti-field=\"body\">\n<h1>Hello</h1>\n<p>World</p>\n</article></body>",
+		},
+		{
+			name:     "markdown rendered as text",
+			rendered: `<body><div class="body">` + marked("1", "# Hello", true) + `</div></body>`,
+			want:     `<body><div class="body" data-plenti-field="body"># Hello</div></body>`,
+		},
+		{
+			name:     "attribute",
+			rendered: `<head><title>` + marked("0", "Hi", false) + `</title></head><body><img alt="` + marked("0", "Hi", false) + `"><br/></body>`,
+			want:     `<head><title data-plenti-field="title">Hi</title></head><body><img alt="Hi"><br/></body>`,
+		},
+		{
+			name:     "only part of the value",
+			rendered: `<body><p>` + editMarkerStart + "0" + editMarkerClose + `Hel...</p></body>`,
+			want:     `<body><p>Hel...</p></body>`,
+		},
+		{
+			name:     "directly in body",
+			rendered: `<body>` + marked("0", "Hi", false) + `</body>`,
+			want:     `<body>Hi</body>`,
+		},
+		{
+			name:     "script",
+			rendered: `<body><script>var a = "<div>` + marked("0", "x", false) + `</div>";</script><p>` + marked("0", "Hi", false) + `</p></body>`,
+			want:     `<body><script>var a = "<div>x</div>";</script><p data-plenti-field="title">Hi</p></body>`,
+		},
+		{
+			name:     "void elements and comments",
+			rendered: `<body><!-- <div> --><section><input name="a"><br><p>` + marked("0", "Hi", false) + `</p></section></body>`,
+			want:     `<body><!-- <div> --><section><input name="a"><br><p data-plenti-field="title">Hi</p></section></body>`,
+		},
+		{
+			name:     "escaped field name",
+			rendered: `<body><p>` + marked("2", "Hi", false) + `</p></body>`,
+			want:     `<body><p data-plenti-field="a&#34;b">Hi</p></body>`,
+		},
+	}
+	fields = append(fields, editableField{Name: `a"b`})
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := editMarkers(test.rendered, fields); got != test.want {
+				t.Errorf("editMarkers() =\n%s\nwant\n%s", got, test.want)
+			}
+		})
+	}
+
+	if got := editMarkers("<p>plain</p>", nil); got != "<p>plain</p>" {
+		t.Errorf("html changed without editable fields: %s", got)
+	}
+}
+
+func TestCheckEditableField(t *testing.T) {
+	chdirTemp(t)
+	writeFiles(t, map[string]string{
+		"content/blog/_blueprint.json": `{"title": "text", "body": "markdown", "date": "date"}`,
+		"content/index.json":           `{"title": "Home"}`,
+	})
+	post := []byte(`{"title": "Post", "body": "# Hi", "date": "2020-01-01", "extra": "x"}`)
+
+	for field, allowed := range map[string]bool{"title": true, "body": true, "date": false, "extra": false, "missing": false} {
+		if err := CheckEditableField("content", "blog/post.json", post, field); (err == nil) != allowed {
+			t.Errorf("field %s: error = %v, want allowed %t", field, err, allowed)
+		}
+	}
+	if err := CheckEditableField("content", "index.json", []byte(`{"title": "Home"}`), "title"); err == nil {
+		t.Error("field without a blueprint was allowed")
+	}
+
+	CheckEditFlag(true)
+	defer CheckEditFlag(false)
+	editable := editableFields("content", "blog/post.json", post)
+	if len(editable) != 2 || editable[0] != (editableField{Name: "body", Markdown: true}) || editable[1] != (editableField{Name: "title"}) {
+		t.Errorf("editable fields = %+v", editable)
+	}
+}
+
+func TestEditSource(t *testing.T) {
+	if got := editSource("blog/post.json"); got != "" {
+		t.Errorf("source without --edit = %q, want none", got)
+	}
+
+	CheckEditFlag(true)
+	defer CheckEditFlag(false)
+	for _, source := range []string{"blog/post.json", `blog/"quoted".json`, `blog/back\slash.json`} {
+		var node map[string]string
+		if err := json.Unmarshal([]byte("{"+strings.TrimSuffix(editSource(source), ",\n")+"}"), &node); err != nil {
+			t.Errorf("source %s is not valid JSON: %v", source, err)
+			continue
+		}
+		if node["source"] != filepath.ToSlash(source) {
+			t.Errorf("source = %q, want %q", node["source"], filepath.ToSlash(source))
+		}
+	}
+}
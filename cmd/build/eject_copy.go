@@ -23,6 +23,10 @@ func EjectCopy(buildPath string, tempBuildDir string, ejectedDir string) error {
 		excludedFiles := []string{
 			ejectedDir + "/build.js",
 		}
+		// The editing bridge only exists when serving with --edit.
+		if !editFlag {
+			excludedFiles = append(excludedFiles, ejectedDir+"/edit.js")
+		}
 		// Check if the current file is in the excluded list.
 		excluded := false
 		for _, excludedFile := range excludedFiles {
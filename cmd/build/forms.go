@@ -0,0 +1,274 @@
+package build
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"plenti/readers"
+	"regexp"
+	"sort"
+	"strings"
+
+	"rogchap.com/v8go"
+)
+
+// Field types that can be declared for a form and rendered as labeled inputs.
+var formFieldTypes = []string{
+	"text",
+	"email",
+	"tel",
+	"url",
+	"number",
+	"date",
+	"textarea",
+	"checkbox",
+}
+
+// Form names are used in ids and paths, so keep them simple.
+var reFormName = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// form is the resolved configuration that gets passed to the ejected form component.
+type form struct {
+	Name     string      `json:"name"`
+	Action   string      `json:"action"`
+	Submit   string      `json:"submit"`
+	Enctype  string      `json:"enctype"`
+	Netlify  bool        `json:"netlify"`
+	Honeypot string      `json:"honeypot"`
+	Mailto   string      `json:"mailto"`
+	Success  string      `json:"success"`
+	Failure  string      `json:"failure"`
+	Fields   []formField `json:"fields"`
+}
+
+type formField struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Label    string `json:"label"`
+	Type     string `json:"type"`
+	Required bool   `json:"required"`
+}
+
+// Compiles the ejectable form components for any forms declared in plenti.json.
+func compileForms(ctx *v8go.Context, SSRctx *v8go.Context, buildPath string, stylePath string, tempBuildDir string, ejectedPath string) error {
+
+	siteConfig, _ := readers.GetSiteConfig(".")
+	if len(siteConfig.Forms) == 0 {
+		return nil
+	}
+
+	Log("\nCompiling " + fmt.Sprint(len(siteConfig.Forms)) + " form(s) declared in plenti.json")
+
+	forms, err := resolveForms(siteConfig)
+	if err != nil {
+		return err
+	}
+	formsJSON, err := json.Marshal(forms)
+	if err != nil {
+		return fmt.Errorf("Unable to marshal forms config: %w", err)
+	}
+
+	// Write the client data source that form.svelte imports.
+	if err = os.MkdirAll(buildPath+"/spa/ejected", os.ModePerm); err != nil {
+		return err
+	}
+	formsJS := "const formsSource = " + string(formsJSON) + ";\n\nexport default formsSource;"
+	if err = ioutil.WriteFile(buildPath+"/spa/ejected/forms.js", []byte(formsJS), 0755); err != nil {
+		return fmt.Errorf("Unable to write forms.js file: %w", err)
+	}
+	// Imports are removed for SSR, so make the same data available as a global.
+	if _, err = SSRctx.RunScript("var formsSource = "+string(formsJSON)+";", "create_ssr"); err != nil {
+		return fmt.Errorf("Could not add forms to SSR: %w", err)
+	}
+
+	if err = compileSvelte(ctx, SSRctx, ejectedPath+"/form.svelte", buildPath+"/spa/ejected/form.js", stylePath, tempBuildDir); err != nil {
+		return err
+	}
+	// Layouts import the form as '../ejected/form.svelte', which resolves to this signature.
+	if _, err = SSRctx.RunScript("var layout_ejected_form_svelte = ejected_form_svelte;", "create_ssr"); err != nil {
+		return fmt.Errorf("Could not alias form component: %w", err)
+	}
+
+	// Success and failure routes are rendered by a simple layout unless content already exists for them.
+	if err = os.MkdirAll(buildPath+"/spa/content", os.ModePerm); err != nil {
+		return err
+	}
+	if err = compileSvelte(ctx, SSRctx, ejectedPath+"/form_result.svelte", buildPath+"/spa/content/form_result.js", stylePath, tempBuildDir); err != nil {
+		return err
+	}
+	if _, err = SSRctx.RunScript("var layout_content_form_result_svelte = ejected_form_result_svelte;", "create_ssr"); err != nil {
+		return fmt.Errorf("Could not alias form result component: %w", err)
+	}
+
+	for _, currentForm := range forms {
+		if currentForm.Netlify {
+			Log("Form '" + currentForm.Name + "' uses Netlify form detection, submissions redirect to " + currentForm.Success)
+		}
+	}
+
+	return nil
+}
+
+// Validates forms from the site config and works out where each one submits to.
+func resolveForms(siteConfig readers.SiteConfig) (map[string]form, error) {
+	forms := map[string]form{}
+	for name, options := range siteConfig.Forms {
+		if !reFormName.MatchString(name) {
+			return nil, fmt.Errorf("Form name '%s' can only contain letters, numbers, underscores, and hyphens", name)
+		}
+		if len(options.Fields) == 0 {
+			return nil, fmt.Errorf("Form '%s' needs at least one field", name)
+		}
+
+		currentForm := form{
+			Name:     name,
+			Enctype:  "application/x-www-form-urlencoded",
+			Honeypot: "bot-field",
+			Mailto:   options.Target.Mailto,
+			Success:  options.Success,
+			Failure:  options.Failure,
+		}
+		if currentForm.Success == "" {
+			currentForm.Success = "/" + name + "/success"
+		}
+		if currentForm.Failure == "" {
+			currentForm.Failure = "/" + name + "/failure"
+		}
+		if currentForm.Success[:1] != "/" || currentForm.Failure[:1] != "/" {
+			return nil, fmt.Errorf("Success and failure routes for form '%s' must start with a forward slash", name)
+		}
+
+		switch {
+		case options.Target.Provider == "netlify":
+			// Netlify detects the form in the static HTML and redirects to the action after a plain POST.
+			currentForm.Netlify = true
+			currentForm.Action = currentForm.Success
+			currentForm.Submit = "/"
+		case options.Target.Provider == "formspree":
+			if options.Target.Endpoint == "" {
+				return nil, fmt.Errorf("Form '%s' uses formspree but has no endpoint", name)
+			}
+			currentForm.Action = options.Target.Endpoint
+			currentForm.Submit = options.Target.Endpoint
+			currentForm.Honeypot = "_gotcha"
+		case options.Target.Provider != "":
+			return nil, fmt.Errorf("Form '%s' has unknown provider '%s'", name, options.Target.Provider)
+		case options.Target.URL != "":
+			currentForm.Action = options.Target.URL
+			currentForm.Submit = options.Target.URL
+		case options.Target.Mailto != "":
+			// Mailto can't be submitted in the background, the browser opens the email client instead.
+			currentForm.Action = "mailto:" + options.Target.Mailto
+			currentForm.Enctype = "text/plain"
+		default:
+			return nil, fmt.Errorf("Form '%s' needs a target provider, url, or mailto", name)
+		}
+
+		fieldNames := map[string]bool{}
+		for _, field := range options.Fields {
+			if !reFormName.MatchString(field.Name) {
+				return nil, fmt.Errorf("Field name '%s' in form '%s' can only contain letters, numbers, underscores, and hyphens", field.Name, name)
+			}
+			if fieldNames[field.Name] || field.Name == currentForm.Honeypot || field.Name == "form-name" {
+				return nil, fmt.Errorf("Field name '%s' is used more than once in form '%s'", field.Name, name)
+			}
+			fieldNames[field.Name] = true
+			fieldType := field.Type
+			if fieldType == "" {
+				fieldType = "text"
+			}
+			if !isFormFieldType(fieldType) {
+				return nil, fmt.Errorf("Field '%s' in form '%s' has unknown type '%s', use one of: %s", field.Name, name, fieldType, strings.Join(formFieldTypes, ", "))
+			}
+			label := field.Label
+			if label == "" {
+				label = strings.Title(strings.ReplaceAll(field.Name, "_", " "))
+			}
+			currentForm.Fields = append(currentForm.Fields, formField{
+				ID:       "form-" + name + "-" + field.Name,
+				Name:     field.Name,
+				Label:    label,
+				Type:     fieldType,
+				Required: field.Required,
+			})
+		}
+		forms[name] = currentForm
+	}
+	return forms, nil
+}
+
+func isFormFieldType(fieldType string) bool {
+	for _, formFieldType := range formFieldTypes {
+		if fieldType == formFieldType {
+			return true
+		}
+	}
+	return false
+}
+
+// The details of a form result node, in the same order as the details of other content.
+type formResultDetails struct {
+	Pager    int             `json:"pager"`
+	Path     string          `json:"path"`
+	Type     string          `json:"type"`
+	Filename string          `json:"filename"`
+	Fields   json.RawMessage `json:"fields"`
+}
+
+// Creates content for form success and failure routes that don't already have a content source.
+func formResults(siteConfig readers.SiteConfig, buildPath string, existingPaths map[string]bool) []content {
+	forms, err := resolveForms(siteConfig)
+	if err != nil {
+		// Invalid forms were already reported when compiling the client.
+		return nil
+	}
+	names := []string{}
+	for name := range forms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	results := []content{}
+	for _, name := range names {
+		currentForm := forms[name]
+		routes := []struct {
+			path     string
+			filename string
+			title    string
+			message  string
+		}{
+			{currentForm.Success, name + "_success.json", "Thank you", "Your submission has been received."},
+			{currentForm.Failure, name + "_failure.json", "Something went wrong", "Your submission could not be sent, please try again."},
+		}
+		for _, route := range routes {
+			if existingPaths[route.path] {
+				continue
+			}
+			existingPaths[route.path] = true
+			fields, _ := json.Marshal(map[string]string{
+				"title":   route.title,
+				"message": route.message,
+				"form":    name,
+			})
+			// Routes come straight from plenti.json, so they're marshalled instead of spliced into the JSON.
+			details, _ := json.Marshal(formResultDetails{
+				Pager:    1,
+				Path:     route.path,
+				Type:     "form_result",
+				Filename: route.filename,
+				Fields:   fields,
+			})
+			results = append(results, content{
+				contentType:     "form_result",
+				contentPath:     route.path,
+				contentDest:     buildPath + route.path + "/index.html",
+				contentDetails:  encodeString(string(details)),
+				contentFilename: route.filename,
+				contentFields:   string(fields),
+				contentKey:      "/form_result/" + route.filename,
+			})
+		}
+	}
+	return results
+}
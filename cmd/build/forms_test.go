@@ -0,0 +1,316 @@
+package build
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"plenti/readers"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// Moves into an empty project folder for the length of a test, since the build reads plenti.json from the current directory.
+func chdirTemp(t *testing.T) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "plenti-build")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		os.Chdir(wd)
+		os.RemoveAll(dir)
+	})
+	return dir
+}
+
+// Writes files relative to the current directory.
+func writeFiles(t *testing.T, files map[string]string) {
+	t.Helper()
+	for path, content := range files {
+		if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestResolveForms(t *testing.T) {
+	email := readers.FormField{Name: "email", Type: "email", Required: true}
+	tests := []struct {
+		name    string
+		options readers.FormOptions
+		err     string
+		check   func(t *testing.T, resolved form)
+	}{
+		{
+			name:    "default routes",
+			options: readers.FormOptions{Fields: []readers.FormField{email}, Target: readers.FormTarget{URL: "https://example.com/submit"}},
+			check: func(t *testing.T, resolved form) {
+				if resolved.Success != "/contact/success" || resolved.Failure != "/contact/failure" {
+					t.Errorf("routes = %s and %s, want /contact/success and /contact/failure", resolved.Success, resolved.Failure)
+				}
+				if resolved.Action != "https://example.com/submit" || resolved.Honeypot != "bot-field" {
+					t.Errorf("action %s with honeypot %s", resolved.Action, resolved.Honeypot)
+				}
+			},
+		},
+		{
+			name: "custom routes",
+			options: readers.FormOptions{
+				Fields:  []readers.FormField{email},
+				Target:  readers.FormTarget{URL: "https://example.com/submit"},
+				Success: "/thanks",
+				Failure: "/oops",
+			},
+			check: func(t *testing.T, resolved form) {
+				if resolved.Success != "/thanks" || resolved.Failure != "/oops" {
+					t.Errorf("routes = %s and %s, want /thanks and /oops", resolved.Success, resolved.Failure)
+				}
+			},
+		},
+		{
+			name:    "relative route",
+			options: readers.FormOptions{Fields: []readers.FormField{email}, Target: readers.FormTarget{URL: "/submit"}, Success: "thanks"},
+			err:     "must start with a forward slash",
+		},
+		{
+			name:    "netlify",
+			options: readers.FormOptions{Fields: []readers.FormField{email}, Target: readers.FormTarget{Provider: "netlify"}},
+			check: func(t *testing.T, resolved form) {
+				if !resolved.Netlify || resolved.Action != "/contact/success" || resolved.Submit != "/" {
+					t.Errorf("netlify form posts to %s (submit %s), want the success route", resolved.Action, resolved.Submit)
+				}
+			},
+		},
+		{
+			name:    "formspree",
+			options: readers.FormOptions{Fields: []readers.FormField{email}, Target: readers.FormTarget{Provider: "formspree", Endpoint: "https://formspree.io/f/abc"}},
+			check: func(t *testing.T, resolved form) {
+				if resolved.Action != "https://formspree.io/f/abc" || resolved.Honeypot != "_gotcha" {
+					t.Errorf("formspree form posts to %s with honeypot %s", resolved.Action, resolved.Honeypot)
+				}
+			},
+		},
+		{
+			name:    "formspree without endpoint",
+			options: readers.FormOptions{Fields: []readers.FormField{email}, Target: readers.FormTarget{Provider: "formspree"}},
+			err:     "has no endpoint",
+		},
+		{
+			name:    "unknown provider",
+			options: readers.FormOptions{Fields: []readers.FormField{email}, Target: readers.FormTarget{Provider: "getform"}},
+			err:     "unknown provider 'getform'",
+		},
+		{
+			name:    "mailto",
+			options: readers.FormOptions{Fields: []readers.FormField{email}, Target: readers.FormTarget{Mailto: "hello@example.com"}},
+			check: func(t *testing.T, resolved form) {
+				if resolved.Action != "mailto:hello@example.com" || resolved.Enctype != "text/plain" {
+					t.Errorf("mailto form posts to %s as %s", resolved.Action, resolved.Enctype)
+				}
+			},
+		},
+		{
+			name:    "no target",
+			options: readers.FormOptions{Fields: []readers.FormField{email}},
+			err:     "needs a target",
+		},
+		{
+			name:    "no fields",
+			options: readers.FormOptions{Target: readers.FormTarget{Provider: "netlify"}},
+			err:     "needs at least one field",
+		},
+		{
+			name:    "duplicate field",
+			options: readers.FormOptions{Fields: []readers.FormField{email, email}, Target: readers.FormTarget{Provider: "netlify"}},
+			err:     "'email' is used more than once",
+		},
+		{
+			name:    "netlify honeypot field",
+			options: readers.FormOptions{Fields: []readers.FormField{{Name: "bot-field"}}, Target: readers.FormTarget{Provider: "netlify"}},
+			err:     "'bot-field' is used more than once",
+		},
+		{
+			name:    "formspree honeypot field",
+			options: readers.FormOptions{Fields: []readers.FormField{{Name: "_gotcha"}}, Target: readers.FormTarget{Provider: "formspree", Endpoint: "https://formspree.io/f/abc"}},
+			err:     "'_gotcha' is used more than once",
+		},
+		{
+			name:    "form-name field",
+			options: readers.FormOptions{Fields: []readers.FormField{{Name: "form-name"}}, Target: readers.FormTarget{Provider: "netlify"}},
+			err:     "'form-name' is used more than once",
+		},
+		{
+			name:    "invalid field name",
+			options: readers.FormOptions{Fields: []readers.FormField{{Name: "first name"}}, Target: readers.FormTarget{Provider: "netlify"}},
+			err:     "can only contain letters",
+		},
+		{
+			name:    "unknown field type",
+			options: readers.FormOptions{Fields: []readers.FormField{{Name: "photo", Type: "file"}}, Target: readers.FormTarget{Provider: "netlify"}},
+			err:     "unknown type 'file'",
+		},
+		{
+			name:    "field defaults",
+			options: readers.FormOptions{Fields: []readers.FormField{{Name: "full_name"}}, Target: readers.FormTarget{Provider: "netlify"}},
+			check: func(t *testing.T, resolved form) {
+				field := resolved.Fields[0]
+				if field.Type != "text" || field.Label != "Full Name" || field.ID != "form-contact-full_name" {
+					t.Errorf("field = %+v", field)
+				}
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			forms, err := resolveForms(readers.SiteConfig{Forms: map[string]readers.FormOptions{"contact": test.options}})
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("error = %v, want one containing %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			test.check(t, forms["contact"])
+		})
+	}
+
+	if _, err := resolveForms(readers.SiteConfig{Forms: map[string]readers.FormOptions{"contact us": {}}}); err == nil {
+		t.Error("form name with a space was accepted")
+	}
+}
+
+func TestFormResults(t *testing.T) {
+	email := readers.FormField{Name: "email", Type: "email"}
+	siteConfig := readers.SiteConfig{Forms: map[string]readers.FormOptions{
+		"contact": {Fields: []readers.FormField{email}, Target: readers.FormTarget{Provider: "netlify"}, Success: `/say "thanks"`, Failure: `/back\slash`},
+		"signup":  {Fields: []readers.FormField{email}, Target: readers.FormTarget{Provider: "netlify"}},
+	}}
+
+	// Routes that already have content are left to it.
+	results := formResults(siteConfig, "public", map[string]bool{"/signup/failure": true})
+	paths := []string{}
+	for _, result := range results {
+		paths = append(paths, result.contentPath)
+		var details struct {
+			Pager    int               `json:"pager"`
+			Path     string            `json:"path"`
+			Type     string            `json:"type"`
+			Filename string            `json:"filename"`
+			Fields   map[string]string `json:"fields"`
+		}
+		if err := json.Unmarshal([]byte(result.contentDetails), &details); err != nil {
+			t.Errorf("details of %s are not valid JSON: %v", result.contentPath, err)
+			continue
+		}
+		if details.Path != result.contentPath || details.Type != "form_result" || details.Filename != result.contentFilename || details.Pager != 1 {
+			t.Errorf("details = %+v for %s", details, result.contentPath)
+		}
+		if form := details.Fields["form"]; !strings.HasPrefix(result.contentFilename, form+"_") {
+			t.Errorf("%s is a result of form %q", result.contentFilename, form)
+		}
+	}
+	if want := []string{`/say "thanks"`, `/back\slash`, "/signup/success"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("form result paths = %q, want %q", paths, want)
+	}
+}
+
+// Renders the form component the same way pages are rendered, before anything hydrates it.
+func TestFormSSR(t *testing.T) {
+	chdirTemp(t)
+	writeFiles(t, map[string]string{
+		"plenti.json": `{
+			"build": "public",
+			"forms": {
+				"contact": {
+					"fields": [
+						{"name": "email", "type": "email", "required": true},
+						{"name": "message", "type": "textarea", "required": true},
+						{"name": "newsletter", "type": "checkbox"}
+					],
+					"target": {"provider": "netlify"}
+				},
+				"signup": {
+					"fields": [{"name": "email", "type": "email", "required": true}],
+					"target": {"provider": "formspree", "endpoint": "https://formspree.io/f/abc"}
+				}
+			}
+		}`,
+		"layout/.keep": "",
+	})
+	if err := NpmDefaults(""); err != nil {
+		t.Fatal(err)
+	}
+	_, ejectedPath, err := EjectTemp("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = EjectCopy("public", "", ejectedPath); err != nil {
+		t.Fatal(err)
+	}
+	if err = Client("public", "", ejectedPath); err != nil {
+		t.Fatal(err)
+	}
+
+	render := func(name string) string {
+		t.Helper()
+		if _, err := SSRctx.RunScript(`var { html } = ejected_form_svelte.render({name: "`+name+`"});`, "create_ssr"); err != nil {
+			t.Fatal(err)
+		}
+		html, err := SSRctx.RunScript("html;", "create_ssr")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return html.String()
+	}
+	contains := func(t *testing.T, html string, patterns ...string) {
+		t.Helper()
+		for _, pattern := range patterns {
+			if !regexp.MustCompile(pattern).MatchString(html) {
+				t.Errorf("rendered form doesn't match %s:\n%s", pattern, html)
+			}
+		}
+	}
+
+	contact := render("contact")
+	formTag := regexp.MustCompile(`<form[^>]*>`).FindString(contact)
+	contains(t, formTag, `action="/contact/success"`, `method="POST"`, `data-netlify="true"`, `netlify-honeypot="bot-field"`)
+	if strings.Contains(formTag, "novalidate") {
+		t.Errorf("form turns off browser validation before it hydrates: %s", formTag)
+	}
+	contains(t, contact,
+		`<input type="hidden" name="form-name" value="contact"`,
+		`<input name="bot-field" tabindex="-1"`,
+		`<input id="form-contact-email" name="email" type="email" required`,
+		`<textarea id="form-contact-message" name="message" required`,
+		`<button type="submit"\s*>Send</button>`,
+	)
+	if regexp.MustCompile(`name="newsletter"[^>]*required`).MatchString(contact) {
+		t.Error("optional checkbox is required")
+	}
+
+	signup := render("signup")
+	formTag = regexp.MustCompile(`<form[^>]*>`).FindString(signup)
+	contains(t, formTag, `action="https://formspree.io/f/abc"`, `method="POST"`)
+	if strings.Contains(formTag, "novalidate") || strings.Contains(formTag, "data-netlify") {
+		t.Errorf("formspree form tag: %s", formTag)
+	}
+	contains(t, signup, `<input name="_gotcha"`, `type="email" required`)
+	if strings.Contains(signup, `name="form-name"`) {
+		t.Error("formspree form includes the netlify form-name field")
+	}
+}
@@ -61,7 +61,26 @@ func Gopack(buildPath string) error {
 		}
 
 	}
+	return convertImports(buildPath, false)
+
+}
+
+// GopackComponents only converts imports of compiled components, reusing the dependencies in "web_modules/" from the last build.
+func GopackComponents(buildPath string) error {
+
+	defer Benchmark(time.Now(), "Running Gopack on components")
+
+	return convertImports(buildPath, true)
+
+}
+
+// Rewrites import paths so the browser can load them directly.
+func convertImports(buildPath string, skipWebModules bool) error {
 	convertErr := filepath.Walk(buildPath+"/spa", func(convertPath string, convertFileInfo os.FileInfo, err error) error {
+		// Dependencies have already been converted by a previous build.
+		if skipWebModules && convertFileInfo.IsDir() && convertPath == buildPath+"/spa/web_modules" {
+			return filepath.SkipDir
+		}
 		if !convertFileInfo.IsDir() && filepath.Ext(convertPath) == ".js" {
 			contentBytes, err := ioutil.ReadFile(convertPath)
 			if err != nil {
@@ -104,6 +123,10 @@ func Gopack(buildPath string) error {
 				pathStr := string(pathBytes)
 				// Remove single or double quotes around path.
 				pathStr = strings.Trim(pathStr, `'"`)
+				// Imports converted by a previous build already point to a file in the build dir.
+				if _, pathExistsErr := os.Stat(buildPath + pathStr); strings.HasPrefix(pathStr, "/") && pathExistsErr == nil {
+					continue
+				}
 				// Make the path relative to the file that is specifying it as an import/export.
 				fullPath := filepath.Dir(convertPath) + "/" + pathStr
 				// Intialize the path that we are replacing.
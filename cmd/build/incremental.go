@@ -0,0 +1,474 @@
+package build
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"plenti/readers"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// BuildManifestVersion is the format version of the incremental build manifest.
+const BuildManifestVersion = 2
+
+// Where the manifest of the last build is kept, outside of the build directory so it never gets deployed.
+const buildManifestPath = ".plenti/cache.json"
+
+// BuildManifest records the inputs and outputs of a build so the next build can skip what didn't change.
+type BuildManifest struct {
+	Version int `json:"version"`
+	// Options are the flags and version the build was made with.
+	Options string `json:"options"`
+	// Inputs are checksums of every input file, grouped by what they affect.
+	Inputs map[string]map[string]string `json:"inputs"`
+	// Pages are the html files that were rendered, relative to the build directory.
+	Pages []string `json:"pages"`
+	// Nodes are the content that was rendered (by path within its store, e.g. "/blog/post1.json") and the pages each one wrote.
+	Nodes map[string]RenderedNode `json:"nodes"`
+}
+
+// RenderedNode is a piece of content and the pages it was rendered to.
+type RenderedNode struct {
+	// Checksum covers everything the node is rendered from besides the components, like its path and fields.
+	Checksum string `json:"checksum"`
+	// Pages are relative to the build directory, e.g. "/blog/post1/index.html".
+	Pages []string `json:"pages"`
+}
+
+// BuildPlan is what needs to be rebuilt.
+type BuildPlan struct {
+	// Full rebuilds everything from scratch.
+	Full bool
+	// Layout rebuilds the client SPA and every page.
+	Layout bool
+	// Content renders the pages of content that changed with components that are only compiled for SSR.
+	Content bool
+	// Assets are files that changed or were added to "assets/".
+	Assets []string
+	// RemovedAssets are files that no longer exist in "assets/".
+	RemovedAssets []string
+	previous      BuildManifest
+	current       BuildManifest
+}
+
+// Categories of inputs and what they trigger when they change.
+const (
+	// Config changes can affect anything, so they rebuild everything.
+	inputConfig = "config"
+	// Ejected core files are copied and compiled along with everything else, so they rebuild everything.
+	inputEjected = "ejected"
+	inputLayout  = "layout"
+	inputContent = "content"
+	inputAssets  = "assets"
+)
+
+// Project files that affect every part of the build.
+var configInputs = []string{
+	"plenti.json",
+	"package.json",
+	"package-lock.json",
+	"netlify.toml",
+}
+
+// Content that was rendered to a page another node kept from the last build, so the page can't be updated on its own.
+var errPageConflict = errors.New("changed content was rendered to a page that other content was kept at")
+
+// Declarations of allContent and passing it to another component unchanged, which don't read it.
+var reAllContentPassed = regexp.MustCompile(`export\s+let\s[^;\n]*|allContent=\{allContent\}|\{allContent\}`)
+
+// Uses of allContent, including through the props of a component.
+var reAllContentUsed = regexp.MustCompile(`\ballContent\b|\$\$props|\$\$restProps`)
+
+// Html files of the last call to DataSource, whether they were rendered or kept from the previous build (including the build directory).
+var renderedPages []string
+
+// Content of the last call to DataSource and the pages of each node.
+var renderedNodes map[string]RenderedNode
+
+// PlanBuild compares the inputs of the project against the last build to work out what needs to run.
+func PlanBuild(buildPath string, siteConfig readers.SiteConfig, tempBuildDir string, ejectedPath string, options string, incremental bool) (BuildPlan, error) {
+
+	current, err := buildInputs(siteConfig, tempBuildDir, ejectedPath, options)
+	if err != nil {
+		return BuildPlan{Full: true}, err
+	}
+	plan := BuildPlan{Full: true, current: current}
+	if !incremental {
+		return plan, nil
+	}
+
+	previous, err := readBuildManifest()
+	if err != nil {
+		fmt.Println("Incremental build: no usable manifest from a previous build, rebuilding everything")
+		return plan, nil
+	}
+	plan.previous = previous
+	if _, err := os.Stat(buildPath); os.IsNotExist(err) {
+		fmt.Println("Incremental build: '" + buildPath + "' doesn't exist, rebuilding everything")
+		return plan, nil
+	}
+	if previous.Options != current.Options {
+		fmt.Println("Incremental build: build options changed, rebuilding everything")
+		return plan, nil
+	}
+	for _, category := range []string{inputConfig, inputEjected} {
+		if changed := changedInputs(previous.Inputs[category], current.Inputs[category]); len(changed) > 0 {
+			fmt.Println("Incremental build: " + strings.Join(changed, ", ") + " changed, rebuilding everything")
+			return plan, nil
+		}
+	}
+
+	plan.Full = false
+	summary := []string{}
+	if changed := changedInputs(previous.Inputs[inputLayout], current.Inputs[inputLayout]); len(changed) > 0 {
+		plan.Layout = true
+		summary = append(summary, fmt.Sprintf("%d layout files", len(changed)))
+		Log("Changed layout files: " + strings.Join(changed, ", "))
+	}
+	if changed := changedInputs(previous.Inputs[inputContent], current.Inputs[inputContent]); len(changed) > 0 {
+		plan.Content = true
+		summary = append(summary, fmt.Sprintf("%d content files", len(changed)))
+		Log("Changed content files: " + strings.Join(changed, ", "))
+	}
+	for _, asset := range changedInputs(previous.Inputs[inputAssets], current.Inputs[inputAssets]) {
+		if _, exists := current.Inputs[inputAssets][asset]; exists {
+			plan.Assets = append(plan.Assets, asset)
+		} else {
+			plan.RemovedAssets = append(plan.RemovedAssets, asset)
+		}
+	}
+	if assets := len(plan.Assets) + len(plan.RemovedAssets); assets > 0 {
+		summary = append(summary, fmt.Sprintf("%d assets", assets))
+	}
+
+	if len(summary) == 0 {
+		fmt.Println("Incremental build: nothing changed since the last build")
+	} else {
+		fmt.Println("Incremental build: " + strings.Join(summary, ", ") + " changed")
+	}
+	return plan, nil
+}
+
+// ClearBuildManifest removes the manifest while building, so a build that doesn't finish is never treated as up to date.
+func ClearBuildManifest() error {
+	if err := os.Remove(buildManifestPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("Unable to remove build manifest: %w", err)
+	}
+	return nil
+}
+
+// WriteBuildManifest saves the inputs and pages of a finished build for the next incremental build.
+func WriteBuildManifest(buildPath string, plan BuildPlan) error {
+	manifest := plan.current
+	manifest.Pages = plan.previous.Pages
+	manifest.Nodes = plan.previous.Nodes
+	if plan.rendered() {
+		manifest.Pages = relativePages(buildPath)
+		manifest.Nodes = renderedNodes
+	}
+	result, err := json.MarshalIndent(manifest, "", "\t")
+	if err != nil {
+		return fmt.Errorf("Unable to marshal build manifest: %w", err)
+	}
+	if err = os.MkdirAll(filepath.Dir(buildManifestPath), os.ModePerm); err != nil {
+		return fmt.Errorf("Unable to create folder for build manifest: %w", err)
+	}
+	if err = ioutil.WriteFile(buildManifestPath, result, 0644); err != nil {
+		return fmt.Errorf("Unable to write build manifest: %w", err)
+	}
+	return nil
+}
+
+// DataSourceChanged builds the data source but only renders content that changed since the last build, plus content sharing a page with it.
+// A page is rendered from its own content and the components, so every page is rendered when a component reads allContent.
+func DataSourceChanged(buildPath string, siteConfig readers.SiteConfig, tempBuildDir string, plan BuildPlan) error {
+	components, err := allContentComponents(tempBuildDir)
+	if err != nil {
+		return err
+	}
+	if len(components) > 0 {
+		fmt.Println("Incremental build: " + strings.Join(components, ", ") + " read allContent, rendering every page")
+		return DataSource(buildPath, siteConfig, tempBuildDir)
+	}
+	err = dataSource(buildPath, siteConfig, tempBuildDir, plan.previous.Nodes)
+	if errors.Is(err, errPageConflict) {
+		fmt.Println("Incremental build: " + err.Error() + ", rendering every page")
+		return DataSource(buildPath, siteConfig, tempBuildDir)
+	}
+	return err
+}
+
+// RemoveStalePages deletes html files from the last build that weren't rendered this time (e.g. content that was deleted or moved).
+func RemoveStalePages(buildPath string, plan BuildPlan) error {
+	if plan.Full || !plan.rendered() {
+		return nil
+	}
+	current := map[string]bool{}
+	for _, page := range relativePages(buildPath) {
+		current[page] = true
+	}
+	for _, page := range plan.previous.Pages {
+		if current[page] {
+			continue
+		}
+		Log("Removing stale page " + page)
+		if err := removeOutput(buildPath, page); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RemoveClientBuild deletes the compiled components so ones that were deleted from "layout/" don't stick around.
+// Dependencies in "web_modules/" and the core files in "ejected/" are kept since they don't come from "layout/".
+func RemoveClientBuild(buildPath string) error {
+	entries, err := ioutil.ReadDir(buildPath + "/spa")
+	if err != nil {
+		return fmt.Errorf("Could not read client build: %w", err)
+	}
+	for _, entry := range entries {
+		if entry.Name() == "web_modules" || entry.Name() == "ejected" {
+			continue
+		}
+		if err = os.RemoveAll(buildPath + "/spa/" + entry.Name()); err != nil {
+			return fmt.Errorf("Could not remove %s from client build: %w", entry.Name(), err)
+		}
+	}
+	return nil
+}
+
+// Gets the pages rendered by this build relative to the build directory, e.g. "/blog/post1/index.html".
+func relativePages(buildPath string) []string {
+	pages := []string{}
+	for _, page := range renderedPages {
+		pages = append(pages, relativePage(buildPath, page))
+	}
+	sort.Strings(pages)
+	return pages
+}
+
+func relativePage(buildPath string, page string) string {
+	return filepath.ToSlash(strings.TrimPrefix(page, buildPath))
+}
+
+// Lists the components in "layout/" that read allContent, which can make any page depend on any content.
+func allContentComponents(tempBuildDir string) ([]string, error) {
+	components := []string{}
+	err := filepath.Walk(tempBuildDir+"layout", func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || filepath.Ext(path) != ".svelte" {
+			return nil
+		}
+		component, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if reAllContentUsed.Match(reAllContentPassed.ReplaceAll(component, nil)) {
+			components = append(components, filepath.ToSlash(strings.TrimPrefix(path, tempBuildDir)))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Could not read layout components: %w", err)
+	}
+	return components, nil
+}
+
+// Checksums what a piece of content is rendered from besides the components.
+func nodeChecksum(currentContent content) string {
+	return checksum([]byte(currentContent.contentDetails + fmt.Sprint(currentContent.contentEditable)))
+}
+
+// Works out which content has to be rendered: content that is new or changed, content that was rendered to the same page as it, and paginated content.
+// Every page a node writes to ends up the same as in a full build, since content later in the list still gets rendered after it.
+func nodesToRender(allContent []content, buildPath string, previousNodes map[string]RenderedNode) map[string]bool {
+	render := map[string]bool{}
+	if previousNodes == nil {
+		return render
+	}
+	// The number of pages is only known once the content is rendered, and the pager entries of content.js come from it.
+	paginated := map[string]bool{}
+	pagers, _ := getPagination()
+	for _, pager := range pagers {
+		if len(pager.paginationVars) > 0 {
+			paginated[pager.contentType] = true
+		}
+	}
+	current := map[string]bool{}
+	// Pages that changed content was rendered to in the last build, or is rendered to now.
+	changedPages := map[string]bool{}
+	for _, currentContent := range allContent {
+		current[currentContent.contentKey] = true
+		if paginated[currentContent.contentType] {
+			render[currentContent.contentKey] = true
+		}
+		previous, existed := previousNodes[currentContent.contentKey]
+		if existed && previous.Checksum == nodeChecksum(currentContent) {
+			continue
+		}
+		render[currentContent.contentKey] = true
+		changedPages[relativePage(buildPath, currentContent.contentDest)] = true
+		for _, page := range previous.Pages {
+			changedPages[page] = true
+		}
+	}
+	for key, previous := range previousNodes {
+		if !current[key] {
+			for _, page := range previous.Pages {
+				changedPages[page] = true
+			}
+		}
+	}
+	for _, currentContent := range allContent {
+		for _, page := range previousNodes[currentContent.contentKey].Pages {
+			if changedPages[page] {
+				render[currentContent.contentKey] = true
+			}
+		}
+	}
+	return render
+}
+
+// Makes sure rendered content didn't write to a page that was kept for other content (e.g. a new pager page).
+func checkPageConflicts(render map[string]bool) error {
+	kept := map[string]bool{}
+	for key, node := range renderedNodes {
+		if !render[key] {
+			for _, page := range node.Pages {
+				kept[page] = true
+			}
+		}
+	}
+	for key, node := range renderedNodes {
+		if !render[key] {
+			continue
+		}
+		for _, page := range node.Pages {
+			if kept[page] {
+				return fmt.Errorf("%w (%s)", errPageConflict, page)
+			}
+		}
+	}
+	return nil
+}
+
+// Whether pages were rendered by this build.
+func (plan BuildPlan) rendered() bool {
+	return plan.Full || plan.Layout || plan.Content
+}
+
+// Removes a file from the build (and its compressed copy), along with any folders that are left empty.
+func removeOutput(buildPath string, file string) error {
+	for _, path := range []string{buildPath + file, buildPath + file + ".gz"} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("Could not remove %s: %w", path, err)
+		}
+	}
+	for dir := filepath.Dir(buildPath + file); dir != buildPath && strings.HasPrefix(dir, buildPath); dir = filepath.Dir(dir) {
+		// Remove fails on folders that still have files in them.
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+	return nil
+}
+
+// Checksums every input of the build, with paths relative to the project.
+func buildInputs(siteConfig readers.SiteConfig, tempBuildDir string, ejectedPath string, options string) (BuildManifest, error) {
+
+	manifest := BuildManifest{
+		Version: BuildManifestVersion,
+		Options: options,
+		Inputs:  map[string]map[string]string{},
+	}
+
+	dirs := map[string][]string{
+		inputEjected: {ejectedPath},
+		inputLayout:  {tempBuildDir + "layout"},
+		inputContent: {tempBuildDir + "content"},
+		inputAssets:  {tempBuildDir + "assets"},
+	}
+	// Content kept outside of "content/" (yaml folders or sqlite databases).
+	for _, storeOptions := range siteConfig.ContentStores {
+		if storeOptions.Path != "" {
+			dirs[inputContent] = append(dirs[inputContent], storeOptions.Path, storeOptions.Path+"-wal")
+		}
+	}
+
+	manifest.Inputs[inputConfig] = map[string]string{}
+	for _, configInput := range configInputs {
+		if content, err := ioutil.ReadFile(configInput); err == nil {
+			manifest.Inputs[inputConfig][configInput] = checksum(content)
+		}
+	}
+	for category, paths := range dirs {
+		manifest.Inputs[category] = map[string]string{}
+		for _, path := range paths {
+			if err := fileChecksums(path, tempBuildDir, manifest.Inputs[category]); err != nil {
+				return manifest, fmt.Errorf("Could not read build inputs: %w", err)
+			}
+		}
+	}
+	return manifest, nil
+}
+
+// Adds the checksum of every file at a path (a single file or a folder) to the checksums map.
+func fileChecksums(root string, tempBuildDir string, checksums map[string]string) error {
+	if _, err := os.Stat(root); os.IsNotExist(err) {
+		return nil
+	}
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		checksums[filepath.ToSlash(strings.TrimPrefix(path, tempBuildDir))] = fmt.Sprintf("%x", sha256.Sum256(content))
+		return nil
+	})
+}
+
+// Lists files that were added, changed, or removed between two sets of checksums, sorted.
+func changedInputs(previous map[string]string, current map[string]string) []string {
+	changed := []string{}
+	for path, hash := range current {
+		if previous[path] != hash {
+			changed = append(changed, path)
+		}
+	}
+	for path := range previous {
+		if _, exists := current[path]; !exists {
+			changed = append(changed, path)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+func readBuildManifest() (BuildManifest, error) {
+	var manifest BuildManifest
+	manifestBytes, err := ioutil.ReadFile(buildManifestPath)
+	if err != nil {
+		return manifest, err
+	}
+	if err = json.Unmarshal(manifestBytes, &manifest); err != nil {
+		return manifest, fmt.Errorf("Unable to read build manifest: %w", err)
+	}
+	if manifest.Version != BuildManifestVersion {
+		return manifest, fmt.Errorf("Build manifest has version %d but this build expects version %d", manifest.Version, BuildManifestVersion)
+	}
+	return manifest, nil
+}
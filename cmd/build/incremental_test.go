@@ -0,0 +1,100 @@
+package build
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestNodesToRender(t *testing.T) {
+	chdirTemp(t)
+	writeFiles(t, map[string]string{"plenti.json": `{"types": {"index": "/:paginate(totalPages)"}}`})
+
+	node := func(key string, path string, fields string) content {
+		return content{
+			contentKey:     key,
+			contentType:    "pages",
+			contentDest:    "public" + path + "/index.html",
+			contentDetails: `{"path": "` + path + `", "fields": ` + fields + `}`,
+		}
+	}
+	previousContent := map[string]content{
+		"/pages/same.json":    node("/pages/same.json", "/same", `{"a": 1}`),
+		"/pages/changed.json": node("/pages/changed.json", "/changed", `{"a": 1}`),
+		"/pages/moved.json":   node("/pages/moved.json", "/moved", `{"a": 1}`),
+		"/pages/removed.json": node("/pages/removed.json", "/removed", `{"a": 1}`),
+		"/pages/under.json":   node("/pages/under.json", "/taken", `{"a": 1}`),
+		"/pages/behind.json":  node("/pages/behind.json", "/removed", `{"a": 1}`),
+		"/pages/old.json":     node("/pages/old.json", "/old", `{"a": 1}`),
+		"/index.json":         node("/index.json", "/", `{"a": 1}`),
+	}
+	previous := map[string]RenderedNode{}
+	for key, previousNode := range previousContent {
+		previous[key] = RenderedNode{Checksum: nodeChecksum(previousNode), Pages: []string{previousNode.contentDest[len("public"):]}}
+	}
+	paginated := node("/index.json", "/", `{"a": 1}`)
+	paginated.contentType = "index"
+	allContent := []content{
+		node("/pages/same.json", "/same", `{"a": 1}`),
+		node("/pages/changed.json", "/changed", `{"a": 2}`),
+		// Moved to a page another node was kept at.
+		node("/pages/moved.json", "/taken", `{"a": 1}`),
+		node("/pages/under.json", "/taken", `{"a": 1}`),
+		// Was overwritten by removed.json, which no longer exists.
+		node("/pages/behind.json", "/removed", `{"a": 1}`),
+		// Now at the page moved.json left.
+		node("/pages/new.json", "/moved", `{"a": 1}`),
+		node("/pages/old.json", "/old", `{"a": 1}`),
+		paginated,
+	}
+
+	if render := nodesToRender(allContent, "public", nil); len(render) != 0 {
+		t.Errorf("without a previous build got %v, want every node rendered in full", render)
+	}
+
+	render := nodesToRender(allContent, "public", previous)
+	got := []string{}
+	for key := range render {
+		got = append(got, key)
+	}
+	sort.Strings(got)
+	want := []string{"/index.json", "/pages/behind.json", "/pages/changed.json", "/pages/moved.json", "/pages/new.json", "/pages/under.json"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("rendered %v, want %v", got, want)
+	}
+
+	renderedNodes = map[string]RenderedNode{
+		"/pages/same.json":    {Pages: []string{"/same/index.html"}},
+		"/pages/changed.json": {Pages: []string{"/changed/index.html"}},
+	}
+	if err := checkPageConflicts(render); err != nil {
+		t.Errorf("unexpected conflict: %v", err)
+	}
+	// A page of a node that was rendered (e.g. a new pager page) that another node was kept at.
+	renderedNodes["/pages/changed.json"] = RenderedNode{Pages: []string{"/changed/index.html", "/same/index.html"}}
+	if err := checkPageConflicts(render); !errors.Is(err, errPageConflict) {
+		t.Errorf("got %v, want a page conflict", err)
+	}
+	renderedNodes = nil
+}
+
+func TestAllContentComponents(t *testing.T) {
+	chdirTemp(t)
+	writeFiles(t, map[string]string{
+		"layout/global/html.svelte":       "<script>\n  export let route, content, allContent;\n</script>\n<svelte:component this={route} {content} {allContent} />\n<Footer allContent={allContent} />",
+		"layout/content/blog.svelte":      "<script>\n  export let title, allContent;\n</script>\n<h1>{title}</h1>",
+		"layout/global/footer.svelte":     "<script>\n  export let allContent;\n</script>\n{#each allContent as content}<a href={content.path}>{content.path}</a>{/each}",
+		"layout/components/spread.svelte": "<Grid {...$$props} />",
+		"layout/components/grid.svelte":   "<script>\n  export let items;\n</script>",
+		"layout/scripts/notes.js":         "// allContent is mentioned here, but it's not a component.",
+	})
+	components, err := allContentComponents("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"layout/components/spread.svelte", "layout/global/footer.svelte"}
+	if !reflect.DeepEqual(components, want) {
+		t.Errorf("got %v, want %v", components, want)
+	}
+}
@@ -0,0 +1,152 @@
+package build
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"plenti/generated"
+	"plenti/readers"
+	"plenti/writers"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Lock resolves the current version of every input the build depends on.
+func Lock(siteConfig readers.SiteConfig, version string) (readers.Lockfile, error) {
+
+	defer Benchmark(time.Now(), "Resolving project inputs for plenti.lock")
+
+	Log("\nResolving project inputs for plenti.lock")
+
+	lockfile := readers.Lockfile{Entries: map[string]string{}}
+
+	// The version of plenti the project was built with.
+	lockfile.Entries["core"] = version
+
+	// Use the ejected core file from the project if it exists, otherwise the default that ships with core.
+	for file, content := range generated.Ejected {
+		if ejectedContent, err := ioutil.ReadFile("ejected" + file); err == nil {
+			content = ejectedContent
+		}
+		lockfile.Entries["ejected:"+file] = checksum(content)
+	}
+
+	// Themes are recorded by the commit pulled and a checksum of the files on disk.
+	themes := []string{}
+	for theme := range siteConfig.ThemeConfig {
+		themes = append(themes, theme)
+	}
+	if _, ok := siteConfig.ThemeConfig[siteConfig.Theme]; siteConfig.Theme != "" && !ok {
+		themes = append(themes, siteConfig.Theme)
+	}
+	for _, theme := range themes {
+		// A theme that isn't on disk (not cloned yet, or removed) only shows up as drift, builds that don't use it still work.
+		if _, err := os.Stat("themes/" + theme); os.IsNotExist(err) {
+			lockfile.Entries["theme:"+theme] = "missing"
+			continue
+		}
+		themeChecksum, err := dirChecksum("themes/" + theme)
+		if err != nil {
+			return lockfile, fmt.Errorf("Could not get checksum for theme '%s': %w", theme, err)
+		}
+		commit := siteConfig.ThemeConfig[theme].Commit
+		if commit == "" {
+			commit = "none"
+		}
+		lockfile.Entries["theme:"+theme] = commit + " " + themeChecksum
+	}
+
+	// NPM dependencies are pinned by their own lockfile.
+	if npmLock, err := ioutil.ReadFile("package-lock.json"); err == nil {
+		lockfile.Entries["npm:package-lock.json"] = checksum(npmLock)
+	}
+
+	return lockfile, nil
+}
+
+// CheckLock compares the current inputs against the lockfile at lockPath and returns what drifted.
+// Frozen builds get an error when the lockfile is missing or anything drifted.
+func CheckLock(lockPath string, current readers.Lockfile, frozen bool) ([]string, error) {
+	locked, err := readers.GetLockfile(lockPath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("Could not read %s: %w", lockPath, err)
+	}
+	if frozen && os.IsNotExist(err) {
+		return nil, fmt.Errorf("The --frozen flag requires a %s file, run \"plenti lock update\" to create one", lockPath)
+	}
+	drift := LockDrift(locked, current)
+	if frozen && len(drift) > 0 {
+		return drift, fmt.Errorf("Build is frozen but the project no longer matches %s, run \"plenti lock update\" if these changes are intentional", lockPath)
+	}
+	return drift, nil
+}
+
+// LockDrift lists every input that changed between the locked and current state.
+func LockDrift(locked readers.Lockfile, current readers.Lockfile) []string {
+	keys := map[string]bool{}
+	for key := range locked.Entries {
+		keys[key] = true
+	}
+	for key := range current.Entries {
+		keys[key] = true
+	}
+	drift := []string{}
+	for key := range keys {
+		lockedValue, inLock := locked.Entries[key]
+		currentValue, inProject := current.Entries[key]
+		switch {
+		case !inLock:
+			drift = append(drift, key+" is not in plenti.lock (now "+currentValue+")")
+		case !inProject:
+			drift = append(drift, key+" is locked to "+lockedValue+" but no longer exists")
+		case lockedValue != currentValue:
+			drift = append(drift, key+" is locked to "+lockedValue+" but is now "+currentValue)
+		}
+	}
+	sort.Strings(drift)
+	return drift
+}
+
+// LockHash identifies the exact set of inputs a build was created from.
+func LockHash(lockfile readers.Lockfile) string {
+	return checksum(writers.LockfileBytes(lockfile))
+}
+
+func checksum(content []byte) string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(content))
+}
+
+// Creates a single checksum from the relative path and contents of every file in a directory.
+func dirChecksum(dir string) (string, error) {
+	hash := sha256.New()
+	files := []string{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && info.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	// Walk is already lexical, but sort anyway so the checksum never depends on traversal order.
+	sort.Strings(files)
+	for _, file := range files {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(hash, "%s\n%d\n", strings.TrimPrefix(file, dir), len(content))
+		hash.Write(content)
+	}
+	return fmt.Sprintf("sha256:%x", hash.Sum(nil)), nil
+}
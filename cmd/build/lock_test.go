@@ -0,0 +1,294 @@
+package build
+
+import (
+	"plenti/readers"
+	"plenti/writers"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLockfileBytes(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries map[string]string
+		want    string
+	}{
+		{
+			name:    "empty",
+			entries: map[string]string{},
+			want:    "",
+		},
+		{
+			name: "sorted keys",
+			entries: map[string]string{
+				"theme:b":               "none sha256:2",
+				"core":                  "v0.2.0",
+				"npm:package-lock.json": "sha256:3",
+				"ejected:/main.js":      "sha256:1",
+				"theme:a":               "abc123 sha256:4",
+			},
+			want: "core v0.2.0\n" +
+				"ejected:/main.js sha256:1\n" +
+				"npm:package-lock.json sha256:3\n" +
+				"theme:a abc123 sha256:4\n" +
+				"theme:b none sha256:2\n",
+		},
+	}
+	header := "# This file is generated by plenti, do not edit it by hand.\n# Run \"plenti lock update\" to intentionally refresh it.\n"
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			lockfile := readers.Lockfile{Entries: test.entries}
+			for i := 0; i < 5; i++ {
+				if got := string(writers.LockfileBytes(lockfile)); got != header+test.want {
+					t.Fatalf("got\n%s\nwant\n%s", got, header+test.want)
+				}
+			}
+		})
+	}
+}
+
+func TestLockfileRoundTrip(t *testing.T) {
+	chdirTemp(t)
+	lockfile := readers.Lockfile{Entries: map[string]string{
+		"core":             "v0.2.0",
+		"ejected:/main.js": "sha256:1",
+		"theme:a":          "abc123 sha256:4",
+		"theme:b":          "missing",
+	}}
+	if err := writers.SetLockfile(lockfile, "plenti.lock"); err != nil {
+		t.Fatal(err)
+	}
+	read, err := readers.GetLockfile("plenti.lock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(read, lockfile) {
+		t.Errorf("read %v, want %v", read.Entries, lockfile.Entries)
+	}
+	if LockHash(read) != LockHash(lockfile) {
+		t.Error("lock hash changed after a round trip")
+	}
+}
+
+func TestGetLockfile(t *testing.T) {
+	tests := []struct {
+		name    string
+		lock    string
+		entries map[string]string
+		err     string
+	}{
+		{
+			name:    "comments and blank lines",
+			lock:    "# comment\n\ncore v0.2.0\n  # indented comment\ntheme:a abc sha256:1\n",
+			entries: map[string]string{"core": "v0.2.0", "theme:a": "abc sha256:1"},
+		},
+		{
+			name:    "windows line endings",
+			lock:    "core v0.2.0\r\ntheme:a abc sha256:1\r\n",
+			entries: map[string]string{"core": "v0.2.0", "theme:a": "abc sha256:1"},
+		},
+		{
+			name: "key without value",
+			lock: "core v0.2.0\ntheme:a\n",
+			err:  "line 2",
+		},
+		{
+			name: "merge conflict",
+			lock: "core v0.2.0\n<<<<<<< HEAD\ntheme:a abc sha256:1\n=======\ntheme:a def sha256:2\n>>>>>>> branch\n",
+			err:  "merge conflict on line 2",
+		},
+		{
+			name: "duplicate key",
+			lock: "core v0.2.0\ntheme:a abc sha256:1\ntheme:a def sha256:2\n",
+			err:  "more than once (line 3)",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			chdirTemp(t)
+			writeFiles(t, map[string]string{"plenti.lock": test.lock})
+			lockfile, err := readers.GetLockfile("plenti.lock")
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("got error %v, want one about %s", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(lockfile.Entries, test.entries) {
+				t.Errorf("got %v, want %v", lockfile.Entries, test.entries)
+			}
+		})
+	}
+}
+
+func TestLockDrift(t *testing.T) {
+	locked := readers.Lockfile{Entries: map[string]string{
+		"core":    "v0.2.0",
+		"theme:a": "abc sha256:1",
+		"theme:b": "def sha256:2",
+	}}
+	tests := []struct {
+		name    string
+		current map[string]string
+		drift   []string
+	}{
+		{
+			name:    "unchanged",
+			current: map[string]string{"core": "v0.2.0", "theme:a": "abc sha256:1", "theme:b": "def sha256:2"},
+			drift:   []string{},
+		},
+		{
+			name:    "added",
+			current: map[string]string{"core": "v0.2.0", "theme:a": "abc sha256:1", "theme:b": "def sha256:2", "theme:c": "none sha256:3"},
+			drift:   []string{"theme:c is not in plenti.lock (now none sha256:3)"},
+		},
+		{
+			name:    "removed",
+			current: map[string]string{"core": "v0.2.0", "theme:a": "abc sha256:1"},
+			drift:   []string{"theme:b is locked to def sha256:2 but no longer exists"},
+		},
+		{
+			name:    "changed",
+			current: map[string]string{"core": "v0.3.0", "theme:a": "abc sha256:1", "theme:b": "missing"},
+			drift: []string{
+				"core is locked to v0.2.0 but is now v0.3.0",
+				"theme:b is locked to def sha256:2 but is now missing",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			drift := LockDrift(locked, readers.Lockfile{Entries: test.current})
+			if !reflect.DeepEqual(drift, test.drift) {
+				t.Errorf("got %v, want %v", drift, test.drift)
+			}
+		})
+	}
+}
+
+func TestDirChecksum(t *testing.T) {
+	chdirTemp(t)
+	writeFiles(t, map[string]string{
+		"a/index.json":      `{"title": "a"}`,
+		"a/layout/x.svelte": "<h1>x</h1>",
+		"a/.git/HEAD":       "ref: refs/heads/main",
+		"b/layout/x.svelte": "<h1>x</h1>",
+		"b/index.json":      `{"title": "a"}`,
+		"c/index.json":      `{"title": "a"}`,
+		"c/layout/y.svelte": "<h1>x</h1>",
+		"d/index.json":      `{"title": "a"}<h1>x</h1>`,
+		"d/layout/x.svelte": "",
+	})
+	sum := func(dir string) string {
+		t.Helper()
+		checksum, err := dirChecksum(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return checksum
+	}
+	a := sum("a")
+	if a != sum("a") {
+		t.Error("checksum isn't stable")
+	}
+	if a != sum("b") {
+		t.Error("checksum depends on the directory name, the order files were written, or .git")
+	}
+	if a == sum("c") {
+		t.Error("renaming a file doesn't change the checksum")
+	}
+	if a == sum("d") {
+		t.Error("moving content between files doesn't change the checksum")
+	}
+	writeFiles(t, map[string]string{"b/layout/x.svelte": "<h1>changed</h1>"})
+	if a == sum("b") {
+		t.Error("changing a file doesn't change the checksum")
+	}
+}
+
+func TestLockMissingTheme(t *testing.T) {
+	chdirTemp(t)
+	writeFiles(t, map[string]string{"themes/present/layout/x.svelte": "<h1>x</h1>"})
+	lockfile, err := Lock(readers.SiteConfig{
+		Theme: "present",
+		ThemeConfig: map[string]readers.ThemeOptions{
+			"present": {Commit: "abc123"},
+			"unused":  {Commit: "def456"},
+		},
+	}, "v0.2.0")
+	if err != nil {
+		t.Fatalf("a theme that isn't on disk stopped the lock: %v", err)
+	}
+	if lockfile.Entries["theme:unused"] != "missing" {
+		t.Errorf("theme:unused = %q, want missing", lockfile.Entries["theme:unused"])
+	}
+	if !strings.HasPrefix(lockfile.Entries["theme:present"], "abc123 sha256:") {
+		t.Errorf("theme:present = %q", lockfile.Entries["theme:present"])
+	}
+}
+
+func TestCheckLock(t *testing.T) {
+	current := readers.Lockfile{Entries: map[string]string{"core": "v0.3.0", "theme:a": "missing"}}
+	tests := []struct {
+		name   string
+		lock   string
+		frozen bool
+		drift  []string
+		err    string
+	}{
+		{
+			name:  "missing lock",
+			drift: []string{"core is not in plenti.lock (now v0.3.0)", "theme:a is not in plenti.lock (now missing)"},
+		},
+		{
+			name:   "missing lock frozen",
+			frozen: true,
+			err:    "requires a plenti.lock file",
+		},
+		{
+			name:   "matching lock frozen",
+			lock:   "core v0.3.0\ntheme:a missing\n",
+			frozen: true,
+			drift:  []string{},
+		},
+		{
+			name:  "drift",
+			lock:  "core v0.2.0\ntheme:a missing\n",
+			drift: []string{"core is locked to v0.2.0 but is now v0.3.0"},
+		},
+		{
+			name:   "drift frozen",
+			lock:   "core v0.2.0\ntheme:a missing\n",
+			frozen: true,
+			drift:  []string{"core is locked to v0.2.0 but is now v0.3.0"},
+			err:    "Build is frozen",
+		},
+		{
+			name: "malformed lock",
+			lock: "core\n",
+			err:  "Could not read plenti.lock",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			chdirTemp(t)
+			if test.lock != "" {
+				writeFiles(t, map[string]string{"plenti.lock": test.lock})
+			}
+			drift, err := CheckLock("plenti.lock", current, test.frozen)
+			if test.err == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+				t.Fatalf("got error %v, want one containing %q", err, test.err)
+			}
+			if test.drift != nil && !reflect.DeepEqual(drift, test.drift) {
+				t.Errorf("got drift %v, want %v", drift, test.drift)
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package build
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Module is a single JavaScript file of the emitted client app.
+type Module struct {
+	// Path is the URL of the module, e.g. "/spa/ejected/main.js".
+	Path string
+	Size int64
+	Hash string
+	// Imports are static imports and exports that are loaded with the module.
+	Imports []string
+	// DynamicImports are import() calls with a literal path that are loaded later (if at all).
+	DynamicImports []string
+	// Unresolved are specifiers that don't point to a file in the build.
+	Unresolved []string
+}
+
+// ModuleGraph is every module reachable from a set of entry points.
+type ModuleGraph struct {
+	Entries []string
+	Modules map[string]*Module
+}
+
+// Static imports and exports, including ones that span multiple lines, e.g. import { a, b } from '/spa/x.js';
+var reModuleFrom = regexp.MustCompile(`(?m)^\s*(?:import|export)\b[^;'"]*?\bfrom\s*['"]([^'"\n]+)['"]`)
+
+// Imports only used for side effects, e.g. import './polyfill.js';
+var reModuleSideEffect = regexp.MustCompile(`(?m)^\s*import\s*['"]([^'"\n]+)['"]`)
+
+// Dynamic imports with a literal path, e.g. import("../content/404.js") (but not "../content/" + type + ".js").
+var reModuleDynamic = regexp.MustCompile(`\bimport\(\s*['"]([^'"\n]+)['"]\s*\)`)
+
+// ReadModuleGraph follows the imports of the entry points through the JavaScript in the build directory.
+func ReadModuleGraph(buildPath string, entries []string) (ModuleGraph, error) {
+
+	graph := ModuleGraph{
+		Entries: entries,
+		Modules: map[string]*Module{},
+	}
+
+	queue := append([]string{}, entries...)
+	for len(queue) > 0 {
+		modulePath := queue[0]
+		queue = queue[1:]
+		if _, seen := graph.Modules[modulePath]; seen {
+			continue
+		}
+
+		moduleBytes, err := ioutil.ReadFile(buildPath + modulePath)
+		if err != nil {
+			return graph, fmt.Errorf("Could not read module %s: %w", modulePath, err)
+		}
+		module := &Module{
+			Path: modulePath,
+			Size: int64(len(moduleBytes)),
+			Hash: fmt.Sprintf("%x", sha256.Sum256(moduleBytes)),
+		}
+		graph.Modules[modulePath] = module
+
+		specifiers := [][]byte{}
+		for _, match := range reModuleFrom.FindAllSubmatch(moduleBytes, -1) {
+			specifiers = append(specifiers, match[1])
+		}
+		for _, match := range reModuleSideEffect.FindAllSubmatch(moduleBytes, -1) {
+			specifiers = append(specifiers, match[1])
+		}
+		for _, specifier := range specifiers {
+			resolved, ok := resolveModule(buildPath, modulePath, string(specifier))
+			if !ok {
+				module.Unresolved = append(module.Unresolved, string(specifier))
+				continue
+			}
+			module.Imports = appendUnique(module.Imports, resolved)
+			queue = append(queue, resolved)
+		}
+		for _, match := range reModuleDynamic.FindAllSubmatch(moduleBytes, -1) {
+			resolved, ok := resolveModule(buildPath, modulePath, string(match[1]))
+			if !ok {
+				module.Unresolved = append(module.Unresolved, string(match[1]))
+				continue
+			}
+			module.DynamicImports = appendUnique(module.DynamicImports, resolved)
+			queue = append(queue, resolved)
+		}
+	}
+
+	return graph, nil
+}
+
+// Reachable lists every module loaded along with an entry point (following static imports only), sorted by path.
+func (graph ModuleGraph) Reachable(entry string) []string {
+	return graph.reachableWithout(entry, "")
+}
+
+// Lists the modules reachable from an entry point without following the imports of one module (which is still included).
+func (graph ModuleGraph) reachableWithout(entry string, skipImports string) []string {
+	seen := map[string]bool{}
+	queue := []string{entry}
+	for len(queue) > 0 {
+		modulePath := queue[0]
+		queue = queue[1:]
+		module, ok := graph.Modules[modulePath]
+		if !ok || seen[modulePath] {
+			continue
+		}
+		seen[modulePath] = true
+		if modulePath != skipImports {
+			queue = append(queue, module.Imports...)
+		}
+	}
+	reachable := make([]string, 0, len(seen))
+	for modulePath := range seen {
+		reachable = append(reachable, modulePath)
+	}
+	sort.Strings(reachable)
+	return reachable
+}
+
+// Unresolved lists "module: specifier" for every import in the graph that doesn't point to a file, sorted.
+func (graph ModuleGraph) Unresolved() []string {
+	unresolved := []string{}
+	for modulePath, module := range graph.Modules {
+		for _, specifier := range module.Unresolved {
+			unresolved = append(unresolved, modulePath+": "+specifier)
+		}
+	}
+	sort.Strings(unresolved)
+	return unresolved
+}
+
+// ClientEntries gets the entry points of the client app: main.js plus the component of each content type (route).
+func ClientEntries(buildPath string) ([]string, error) {
+	entries := []string{"/spa/ejected/main.js"}
+	files, err := ioutil.ReadDir(buildPath + "/spa/content")
+	if err != nil {
+		return nil, fmt.Errorf("Could not read content components: %w", err)
+	}
+	for _, file := range files {
+		if !file.IsDir() && filepath.Ext(file.Name()) == ".js" {
+			entries = append(entries, "/spa/content/"+file.Name())
+		}
+	}
+	return entries, nil
+}
+
+// Turns an import specifier into the URL of a file in the build, the way the browser would resolve it.
+func resolveModule(buildPath string, importer string, specifier string) (string, bool) {
+	var resolved string
+	switch {
+	case strings.HasPrefix(specifier, "/"):
+		resolved = path.Clean(specifier)
+	case strings.HasPrefix(specifier, "./") || strings.HasPrefix(specifier, "../"):
+		resolved = path.Join(path.Dir(importer), specifier)
+	default:
+		// Bare specifiers (e.g. "svelte/internal") can't be loaded by the browser without Gopack.
+		return "", false
+	}
+	info, err := os.Stat(buildPath + resolved)
+	if err != nil || info.IsDir() {
+		return "", false
+	}
+	return resolved, true
+}
+
+func appendUnique(list []string, value string) []string {
+	for _, existing := range list {
+		if existing == value {
+			return list
+		}
+	}
+	return append(list, value)
+}
@@ -0,0 +1,434 @@
+package build
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"plenti/readers"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// OptimizeOption is a performance option that --optimize can turn on, along with why it was or wasn't.
+type OptimizeOption struct {
+	Name    string
+	Enabled bool
+	Reason  string
+}
+
+// Names of the options in the order they are applied (each can be turned off with --no-<name>).
+const (
+	OptimizeMinifyHTML = "minify-html"
+	OptimizeCompress   = "compress"
+	OptimizePreload    = "preload"
+)
+
+// Extensions of text files that are worth compressing.
+var compressExtensions = []string{
+	".html",
+	".js",
+	".css",
+	".json",
+	".svg",
+	".xml",
+	".txt",
+}
+
+// Files smaller than this usually don't get any smaller when compressed.
+const compressMinSize = 1024
+
+// Number of compressed files checked after the build.
+const compressSampleSize = 20
+
+const mainScript = "/spa/ejected/main.js"
+
+// OptimizeOptions picks the recommended performance options for what the build actually contains.
+func OptimizeOptions(buildPath string, siteConfig readers.SiteConfig, disabled map[string]bool) ([]OptimizeOption, error) {
+
+	pages, err := htmlFiles(buildPath)
+	if err != nil {
+		return nil, err
+	}
+	var htmlSize int64
+	usesMain := false
+	for _, page := range pages {
+		pageBytes, err := ioutil.ReadFile(page)
+		if err != nil {
+			return nil, fmt.Errorf("Could not read %s: %w", page, err)
+		}
+		htmlSize += int64(len(pageBytes))
+		if bytes.Contains(pageBytes, []byte(mainScript)) {
+			usesMain = true
+		}
+	}
+
+	minify := OptimizeOption{Name: OptimizeMinifyHTML}
+	if len(pages) == 0 {
+		minify.Reason = "no html pages were built"
+	} else {
+		minify.Enabled = true
+		minify.Reason = fmt.Sprintf("%d pages (%s of html) include whitespace between tags that browsers don't need", len(pages), formatBytes(htmlSize))
+	}
+
+	compress := OptimizeOption{Name: OptimizeCompress}
+	if netlify := usesNetlify(siteConfig); netlify != "" {
+		compress.Reason = "Netlify compresses responses itself (" + netlify + ")"
+	} else {
+		compress.Enabled = true
+		compress.Reason = "adds .gz files next to text files so hosts that support precompressed files (e.g. nginx gzip_static) don't compress on every request"
+	}
+
+	preload := OptimizeOption{Name: OptimizePreload}
+	if !usesMain {
+		preload.Reason = "pages don't load " + mainScript
+	} else {
+		graph, err := ReadModuleGraph(buildPath, []string{mainScript})
+		if err != nil {
+			return nil, err
+		}
+		if imports := len(graph.Reachable(mainScript)) - 1; imports > 0 {
+			preload.Enabled = true
+			preload.Reason = fmt.Sprintf("%s statically imports %d modules, preloading them avoids a waterfall of requests", mainScript, imports)
+		} else {
+			preload.Reason = mainScript + " doesn't import any other modules"
+		}
+	}
+
+	options := []OptimizeOption{minify, compress, preload}
+	for i := range options {
+		if disabled[options[i].Name] {
+			options[i].Enabled = false
+			options[i].Reason = "turned off with --no-" + options[i].Name
+		}
+	}
+	return options, nil
+}
+
+// EnabledOptions lists the names of the options that are turned on.
+func EnabledOptions(options []OptimizeOption) []string {
+	enabled := []string{}
+	for _, option := range options {
+		if option.Enabled {
+			enabled = append(enabled, option.Name)
+		}
+	}
+	return enabled
+}
+
+func optionEnabled(options []OptimizeOption, name string) bool {
+	for _, option := range options {
+		if option.Name == name {
+			return option.Enabled
+		}
+	}
+	return false
+}
+
+// Optimize applies the enabled performance options to the build directory.
+func Optimize(buildPath string, options []OptimizeOption) error {
+
+	defer Benchmark(time.Now(), "Optimizing build")
+
+	fmt.Println("\nOptimizing build:")
+	for _, option := range options {
+		state := "off"
+		if option.Enabled {
+			state = "on "
+		}
+		fmt.Printf("  %s %-12s %s\n", state, option.Name, option.Reason)
+	}
+
+	minify := optionEnabled(options, OptimizeMinifyHTML)
+	preload := optionEnabled(options, OptimizePreload)
+
+	if minify || preload {
+		preloads := ""
+		if preload {
+			graph, err := ReadModuleGraph(buildPath, []string{mainScript})
+			if err != nil {
+				return err
+			}
+			for _, modulePath := range graph.Reachable(mainScript) {
+				if modulePath != mainScript {
+					preloads += "<link rel=\"modulepreload\" href=\"" + modulePath + "\">"
+				}
+			}
+		}
+		pages, err := htmlFiles(buildPath)
+		if err != nil {
+			return err
+		}
+		for _, page := range pages {
+			pageBytes, err := ioutil.ReadFile(page)
+			if err != nil {
+				return fmt.Errorf("Could not read %s to optimize: %w", page, err)
+			}
+			optimized := pageBytes
+			if minify {
+				optimized = minifyHTML(optimized)
+			}
+			if preload {
+				optimized = addPreloads(optimized, preloads)
+			}
+			if err = ioutil.WriteFile(page, optimized, 0644); err != nil {
+				return fmt.Errorf("Could not write optimized %s: %w", page, err)
+			}
+		}
+	}
+
+	// Compress last so the .gz files match the final version of each file.
+	if optionEnabled(options, OptimizeCompress) {
+		compressed, err := compressFiles(buildPath)
+		if err != nil {
+			return err
+		}
+		Log(fmt.Sprintf("Compressed %d files", compressed))
+	}
+
+	return nil
+}
+
+// Elements where whitespace is meaningful (or isn't html), so they are left exactly as they are.
+var reWhitespaceSensitive = regexp.MustCompile(`(?is)<pre\b.*?</pre>|<textarea\b.*?</textarea>|<script\b.*?</script>|<style\b.*?</style>`)
+
+// Whitespace between two tags.
+var reBetweenTags = regexp.MustCompile(`>\s+<`)
+
+// Collapses whitespace between tags to a single character.
+// Whitespace isn't removed completely since it can still separate inline elements, and hydration expects the text node to exist.
+func minifyHTML(html []byte) []byte {
+	protected := reWhitespaceSensitive.FindAllIndex(html, -1)
+	var minified bytes.Buffer
+	last := 0
+	for _, match := range reBetweenTags.FindAllIndex(html, -1) {
+		if insideRanges(match[0]+1, protected) {
+			continue
+		}
+		minified.Write(html[last:match[0]])
+		if bytes.ContainsAny(html[match[0]:match[1]], "\r\n") {
+			minified.WriteString(">\n<")
+		} else {
+			minified.WriteString("> <")
+		}
+		last = match[1]
+	}
+	minified.Write(html[last:])
+	return minified.Bytes()
+}
+
+func insideRanges(index int, ranges [][]int) bool {
+	for _, indexRange := range ranges {
+		if index >= indexRange[0] && index < indexRange[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// Adds modulepreload links to the head of pages that load the client app (pages that already have them are skipped).
+func addPreloads(html []byte, preloads string) []byte {
+	if preloads == "" || !bytes.Contains(html, []byte(mainScript)) || bytes.Contains(html, []byte(`rel="modulepreload"`)) {
+		return html
+	}
+	if bytes.Contains(html, []byte("</head>")) {
+		return bytes.Replace(html, []byte("</head>"), []byte(preloads+"</head>"), 1)
+	}
+	return html
+}
+
+// Writes a .gz file next to every text file that gets smaller when compressed.
+func compressFiles(buildPath string) (int, error) {
+	compressed := 0
+	err := filepath.Walk(buildPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || info.Size() < compressMinSize || !isCompressible(path) {
+			return nil
+		}
+		fileBytes, err := ioutil.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("Could not read %s to compress: %w", path, err)
+		}
+		var gzipped bytes.Buffer
+		writer, err := gzip.NewWriterLevel(&gzipped, gzip.BestCompression)
+		if err != nil {
+			return err
+		}
+		if _, err = writer.Write(fileBytes); err != nil {
+			return fmt.Errorf("Could not compress %s: %w", path, err)
+		}
+		if err = writer.Close(); err != nil {
+			return fmt.Errorf("Could not compress %s: %w", path, err)
+		}
+		if gzipped.Len() >= len(fileBytes) {
+			return nil
+		}
+		if err = ioutil.WriteFile(path+".gz", gzipped.Bytes(), 0644); err != nil {
+			return fmt.Errorf("Could not write compressed %s: %w", path, err)
+		}
+		compressed++
+		return nil
+	})
+	if err != nil {
+		return compressed, fmt.Errorf("Could not compress build output: %w", err)
+	}
+	return compressed, nil
+}
+
+func isCompressible(path string) bool {
+	ext := filepath.Ext(path)
+	for _, compressExtension := range compressExtensions {
+		if ext == compressExtension {
+			return true
+		}
+	}
+	return false
+}
+
+// VerifyOptimized runs quick checks that the optimized build still works.
+func VerifyOptimized(buildPath string, options []OptimizeOption) error {
+
+	defer Benchmark(time.Now(), "Verifying optimized build")
+
+	problems := []string{}
+
+	// Every import has to point to a file the browser can load.
+	entries, err := ClientEntries(buildPath)
+	if err != nil {
+		return err
+	}
+	graph, err := ReadModuleGraph(buildPath, entries)
+	if err != nil {
+		return err
+	}
+	for _, unresolved := range graph.Unresolved() {
+		problems = append(problems, "unresolved import in "+unresolved)
+	}
+
+	// Every page has to reference assets that exist.
+	pages, err := htmlFiles(buildPath)
+	if err != nil {
+		return err
+	}
+	for _, page := range pages {
+		pageBytes, err := ioutil.ReadFile(page)
+		if err != nil {
+			return fmt.Errorf("Could not read %s to verify: %w", page, err)
+		}
+		for _, missing := range missingAssets(buildPath, pageBytes) {
+			problems = append(problems, "missing asset "+missing+" referenced in "+page)
+		}
+	}
+
+	// Compressed files have to decompress to the file they sit next to.
+	if optionEnabled(options, OptimizeCompress) {
+		gzipped := []string{}
+		filepath.Walk(buildPath, func(path string, info os.FileInfo, err error) error {
+			if err == nil && !info.IsDir() && filepath.Ext(path) == ".gz" {
+				gzipped = append(gzipped, path)
+			}
+			return nil
+		})
+		for _, path := range sampleFiles(gzipped, compressSampleSize) {
+			if err := checkCompressed(path); err != nil {
+				problems = append(problems, err.Error())
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("Optimized build failed verification:\n  %s", strings.Join(problems, "\n  "))
+	}
+	fmt.Printf("Verified optimized build: %d modules resolve, %d pages reference existing assets\n", len(graph.Modules), len(pages))
+	return nil
+}
+
+// Lists local assets (references with a file extension) in a page that aren't in the build directory.
+func missingAssets(buildPath string, html []byte) []string {
+	missing := []string{}
+	seen := map[string]bool{}
+	for _, match := range reLocalReference.FindAllSubmatch(html, -1) {
+		reference := string(match[1])
+		// Skip protocol relative urls (//example.com) and links to other pages.
+		if seen[reference] || strings.HasPrefix(reference, "//") || filepath.Ext(reference) == "" {
+			continue
+		}
+		seen[reference] = true
+		if _, err := os.Stat(buildPath + reference); err != nil {
+			missing = append(missing, reference)
+		}
+	}
+	return missing
+}
+
+func checkCompressed(path string) error {
+	gzipped, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("could not open %s: %s", path, err)
+	}
+	defer gzipped.Close()
+	reader, err := gzip.NewReader(gzipped)
+	if err != nil {
+		return fmt.Errorf("could not decompress %s: %s", path, err)
+	}
+	decompressed, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return fmt.Errorf("could not decompress %s: %s", path, err)
+	}
+	original, err := ioutil.ReadFile(strings.TrimSuffix(path, ".gz"))
+	if err != nil {
+		return fmt.Errorf("compressed file %s has no original: %s", path, err)
+	}
+	if !bytes.Equal(decompressed, original) {
+		return fmt.Errorf("compressed file %s doesn't match the original", path)
+	}
+	return nil
+}
+
+// Picks files spread evenly through the list.
+func sampleFiles(files []string, size int) []string {
+	if len(files) <= size {
+		return files
+	}
+	sample := []string{}
+	step := float64(len(files)) / float64(size)
+	for i := 0; i < size; i++ {
+		sample = append(sample, files[int(float64(i)*step)])
+	}
+	return sample
+}
+
+func htmlFiles(buildPath string) ([]string, error) {
+	pages := []string{}
+	err := filepath.Walk(buildPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && filepath.Ext(path) == ".html" {
+			pages = append(pages, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Could not find html pages: %w", err)
+	}
+	return pages, nil
+}
+
+// Finds what turns on Netlify for the site, empty if nothing does.
+func usesNetlify(siteConfig readers.SiteConfig) string {
+	if _, err := os.Stat("netlify.toml"); err == nil {
+		return "found netlify.toml"
+	}
+	for name, form := range siteConfig.Forms {
+		if form.Target.Provider == "netlify" {
+			return "form '" + name + "' submits to netlify"
+		}
+	}
+	return ""
+}
@@ -0,0 +1,318 @@
+package build
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SizeBaselineVersion is the format version written to size baseline files.
+const SizeBaselineVersion = 2
+
+// SizeReport holds the output sizes of a build, as stored in the size baseline file.
+type SizeReport struct {
+	Version    int                     `json:"version"`
+	Pages      int                     `json:"pages"`
+	Categories map[string]SizeCategory `json:"categories"`
+	Routes     map[string]int64        `json:"routes"`
+}
+
+// SizeCategory is the total and per page average of a tracked size (in bytes).
+type SizeCategory struct {
+	Total   int64 `json:"total"`
+	Average int64 `json:"average"`
+}
+
+// SizeDelta is the change of a single category between two builds.
+type SizeDelta struct {
+	Category       string
+	Before         SizeCategory
+	After          SizeCategory
+	TotalPercent   float64
+	AveragePercent float64
+	Regressed      bool
+}
+
+// Categories in the order they are displayed.
+var sizeCategories = []string{
+	"html",
+	"js",
+	"content_data",
+	"largest_route",
+	"first_paint",
+}
+
+// Categories that grow with every page added, so they are judged on per page averages.
+// The rest (client js is shared by every page) are judged on totals.
+var perPageCategories = map[string]bool{
+	"html":         true,
+	"content_data": true,
+	"first_paint":  true,
+}
+
+// The client data source holds every content node, so it grows with content instead of with the client code.
+const contentDataPath = "/spa/ejected/content.js"
+
+// SizeBaseline compares the output sizes of the current build against a committed baseline file.
+func SizeBaseline(buildPath string, baselinePath string, tolerance float64, update bool) error {
+
+	defer Benchmark(time.Now(), "Comparing output sizes against baseline")
+
+	Log("\nMeasuring output sizes of the '" + buildPath + "' build directory")
+
+	after, err := MeasureSizes(buildPath)
+	if err != nil {
+		return err
+	}
+
+	if update {
+		fmt.Printf("Updating size baseline '%s'.\n", baselinePath)
+		return writeSizeBaseline(baselinePath, after)
+	}
+
+	before, err := readSizeBaseline(baselinePath)
+	if os.IsNotExist(err) {
+		fmt.Printf("No size baseline found at '%s', creating it from this build.\n", baselinePath)
+		return writeSizeBaseline(baselinePath, after)
+	}
+	if err != nil {
+		return err
+	}
+
+	deltas, regressed := CompareSizes(before, after, tolerance)
+
+	fmt.Printf("\nOutput sizes compared to '%s' (tolerance %.1f%%):\n", baselinePath, tolerance)
+	fmt.Printf("  pages %d (%+d)\n", after.Pages, after.Pages-before.Pages)
+	for _, delta := range deltas {
+		marker := ""
+		if delta.Regressed {
+			marker = "  <- regression"
+		}
+		if !perPageCategories[delta.Category] {
+			fmt.Printf("  %-14s total %s (%s, %+.1f%%)%s\n",
+				delta.Category,
+				formatBytes(delta.After.Total),
+				formatByteDelta(delta.After.Total-delta.Before.Total),
+				delta.TotalPercent,
+				marker,
+			)
+			continue
+		}
+		fmt.Printf("  %-14s total %s (%s, %+.1f%%)  per page %s (%s, %+.1f%%)%s\n",
+			delta.Category,
+			formatBytes(delta.After.Total),
+			formatByteDelta(delta.After.Total-delta.Before.Total),
+			delta.TotalPercent,
+			formatBytes(delta.After.Average),
+			formatByteDelta(delta.After.Average-delta.Before.Average),
+			delta.AveragePercent,
+			marker,
+		)
+	}
+
+	// Show individual routes that grew beyond the tolerance to help find the cause.
+	for _, route := range sortedRoutes(after.Routes) {
+		beforeSize, ok := before.Routes[route]
+		if !ok {
+			continue
+		}
+		if percent := percentChange(beforeSize, after.Routes[route]); percent > tolerance {
+			fmt.Printf("  route %s first paint %s (%+.1f%%)\n", route, formatBytes(after.Routes[route]), percent)
+		}
+	}
+
+	if len(regressed) > 0 {
+		return fmt.Errorf("Output size regressed beyond %.1f%% tolerance for: %s (run with --update-size-baseline if this is intentional)", tolerance, strings.Join(regressed, ", "))
+	}
+	return nil
+
+}
+
+// CompareSizes calculates the change for every category and lists the ones that regressed.
+// Categories that scale with the number of pages are judged on per page averages so adding content doesn't count as growth,
+// everything else is judged on totals so content growth can't hide it.
+func CompareSizes(before SizeReport, after SizeReport, tolerance float64) ([]SizeDelta, []string) {
+	deltas := []SizeDelta{}
+	regressed := []string{}
+	for _, category := range sizeCategories {
+		delta := SizeDelta{
+			Category: category,
+			Before:   before.Categories[category],
+			After:    after.Categories[category],
+		}
+		delta.TotalPercent = percentChange(delta.Before.Total, delta.After.Total)
+		delta.AveragePercent = percentChange(delta.Before.Average, delta.After.Average)
+		percent := delta.TotalPercent
+		if perPageCategories[category] {
+			percent = delta.AveragePercent
+		}
+		if percent > tolerance {
+			delta.Regressed = true
+			regressed = append(regressed, category)
+		}
+		deltas = append(deltas, delta)
+	}
+	return deltas, regressed
+}
+
+// MeasureSizes collects the per category output sizes of a build directory.
+func MeasureSizes(buildPath string) (SizeReport, error) {
+
+	report := SizeReport{
+		Version:    SizeBaselineVersion,
+		Categories: map[string]SizeCategory{},
+		Routes:     map[string]int64{},
+	}
+
+	var htmlTotal, jsTotal, contentDataTotal, largestRoute, firstPaintTotal int64
+
+	err := filepath.Walk(buildPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		// All JS that could be shipped to the client lives in the spa folder.
+		if path == buildPath+contentDataPath {
+			contentDataTotal = info.Size()
+		} else if filepath.Ext(path) == ".js" && strings.HasPrefix(path, buildPath+"/spa/") {
+			jsTotal += info.Size()
+		}
+		if info.Name() != "index.html" {
+			return nil
+		}
+
+		htmlBytes, err := ioutil.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("Could not read %s to measure size: %w", path, err)
+		}
+		firstPaint, payload := routeSizes(buildPath, htmlBytes)
+
+		route := strings.TrimSuffix(strings.TrimPrefix(path, buildPath), "index.html")
+		report.Routes[route] = firstPaint
+		report.Pages++
+
+		htmlTotal += info.Size()
+		firstPaintTotal += firstPaint
+		if payload > largestRoute {
+			largestRoute = payload
+		}
+		return nil
+	})
+	if err != nil {
+		return report, fmt.Errorf("Could not measure build output: %w", err)
+	}
+
+	report.Categories["html"] = SizeCategory{Total: htmlTotal, Average: perPage(htmlTotal, report.Pages)}
+	// Every page can load any of the client js, so its per page cost is the total.
+	report.Categories["js"] = SizeCategory{Total: jsTotal, Average: jsTotal}
+	// Each page adds a node to the data source, so its per page cost is the share of a single page.
+	report.Categories["content_data"] = SizeCategory{Total: contentDataTotal, Average: perPage(contentDataTotal, report.Pages)}
+	report.Categories["largest_route"] = SizeCategory{Total: largestRoute, Average: largestRoute}
+	report.Categories["first_paint"] = SizeCategory{Total: firstPaintTotal, Average: perPage(firstPaintTotal, report.Pages)}
+
+	return report, nil
+}
+
+// Match local files referenced from html, e.g. src="/spa/ejected/main.js" or href='/spa/bundle.css'.
+var reLocalReference = regexp.MustCompile(`(?:src|href)=["'](/[^"'?#]*)`)
+
+// Gets the first paint size (html, css, and js) and the full payload (every local file referenced) of a route.
+// The content data source is left out (e.g. when it's preloaded), it's measured on its own since it grows with content.
+func routeSizes(buildPath string, htmlBytes []byte) (int64, int64) {
+	firstPaint := int64(len(htmlBytes))
+	payload := firstPaint
+	seen := map[string]bool{}
+	for _, match := range reLocalReference.FindAllSubmatch(htmlBytes, -1) {
+		reference := string(match[1])
+		if seen[reference] || reference == contentDataPath {
+			continue
+		}
+		seen[reference] = true
+		info, err := os.Stat(buildPath + reference)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		payload += info.Size()
+		if ext := filepath.Ext(reference); ext == ".css" || ext == ".js" {
+			firstPaint += info.Size()
+		}
+	}
+	return firstPaint, payload
+}
+
+func readSizeBaseline(baselinePath string) (SizeReport, error) {
+	var report SizeReport
+	baselineBytes, err := ioutil.ReadFile(baselinePath)
+	if err != nil {
+		return report, err
+	}
+	if err = json.Unmarshal(baselineBytes, &report); err != nil {
+		return report, fmt.Errorf("Unable to read size baseline %s: %w", baselinePath, err)
+	}
+	if report.Version != SizeBaselineVersion {
+		return report, fmt.Errorf("Size baseline %s has version %d but this build expects version %d, run with --update-size-baseline to recreate it", baselinePath, report.Version, SizeBaselineVersion)
+	}
+	return report, nil
+}
+
+func writeSizeBaseline(baselinePath string, report SizeReport) error {
+	// Map keys are sorted when marshalled so the file is stable between builds.
+	result, err := json.MarshalIndent(report, "", "\t")
+	if err != nil {
+		return fmt.Errorf("Unable to marshal size baseline: %w", err)
+	}
+	if err = ioutil.WriteFile(baselinePath, append(result, '\n'), 0644); err != nil {
+		return fmt.Errorf("Unable to write size baseline %s: %w", baselinePath, err)
+	}
+	return nil
+}
+
+func perPage(total int64, pages int) int64 {
+	if pages == 0 {
+		return 0
+	}
+	return total / int64(pages)
+}
+
+func percentChange(before int64, after int64) float64 {
+	if before == 0 {
+		if after == 0 {
+			return 0
+		}
+		// Anything appearing from nothing counts as fully new.
+		return 100
+	}
+	return math.Round(float64(after-before)/float64(before)*1000) / 10
+}
+
+func sortedRoutes(routes map[string]int64) []string {
+	keys := make([]string, 0, len(routes))
+	for route := range routes {
+		keys = append(keys, route)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatBytes(size int64) string {
+	if size < 1024 && size > -1024 {
+		return fmt.Sprintf("%d B", size)
+	}
+	return fmt.Sprintf("%.1f KB", float64(size)/1024)
+}
+
+func formatByteDelta(size int64) string {
+	if size >= 0 {
+		return "+" + formatBytes(size)
+	}
+	return formatBytes(size)
+}
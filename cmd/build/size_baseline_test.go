@@ -0,0 +1,269 @@
+package build
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Builds a size report the way MeasureSizes would for the given totals.
+func sizeReport(pages int, html, js, contentData, largestRoute, firstPaint int64) SizeReport {
+	return SizeReport{
+		Version: SizeBaselineVersion,
+		Pages:   pages,
+		Categories: map[string]SizeCategory{
+			"html":          {Total: html, Average: perPage(html, pages)},
+			"js":            {Total: js, Average: js},
+			"content_data":  {Total: contentData, Average: perPage(contentData, pages)},
+			"largest_route": {Total: largestRoute, Average: largestRoute},
+			"first_paint":   {Total: firstPaint, Average: perPage(firstPaint, pages)},
+		},
+	}
+}
+
+func TestCompareSizes(t *testing.T) {
+	before := sizeReport(10, 100000, 200000, 50000, 60000, 300000)
+	tests := []struct {
+		name      string
+		after     SizeReport
+		regressed []string
+	}{
+		{
+			name:      "unchanged",
+			after:     before,
+			regressed: []string{},
+		},
+		{
+			// Twice the pages with the same html per page and the same client js, the data source grows with the content.
+			name:      "content growth",
+			after:     sizeReport(20, 200000, 200000, 100000, 60000, 600000),
+			regressed: []string{},
+		},
+		{
+			name:      "js regression",
+			after:     sizeReport(10, 100000, 240000, 50000, 100000, 300000),
+			regressed: []string{"js", "largest_route"},
+		},
+		{
+			// Growing content must not hide the extra js by lowering a per page average.
+			name:      "js regression with content growth",
+			after:     sizeReport(40, 400000, 240960, 200000, 60000, 1200000),
+			regressed: []string{"js"},
+		},
+		{
+			name:      "content data regression",
+			after:     sizeReport(10, 100000, 200000, 60000, 60000, 300000),
+			regressed: []string{"content_data"},
+		},
+		{
+			name:      "html regression",
+			after:     sizeReport(10, 120000, 200000, 50000, 60000, 300000),
+			regressed: []string{"html"},
+		},
+		{
+			name:      "within tolerance",
+			after:     sizeReport(10, 104000, 209000, 52000, 62000, 312000),
+			regressed: []string{},
+		},
+		{
+			name:      "smaller",
+			after:     sizeReport(10, 50000, 100000, 25000, 30000, 150000),
+			regressed: []string{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			deltas, regressed := CompareSizes(before, test.after, 5)
+			if !reflect.DeepEqual(regressed, test.regressed) {
+				t.Errorf("regressed = %v, want %v", regressed, test.regressed)
+			}
+			if len(deltas) != len(sizeCategories) {
+				t.Fatalf("got %d deltas, want %d", len(deltas), len(sizeCategories))
+			}
+			for i, delta := range deltas {
+				if delta.Category != sizeCategories[i] {
+					t.Errorf("delta %d is %s, want %s", i, delta.Category, sizeCategories[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCompareSizesZeroBaseline(t *testing.T) {
+	before := sizeReport(0, 0, 0, 0, 0, 0)
+
+	_, regressed := CompareSizes(before, before, 5)
+	if len(regressed) != 0 {
+		t.Errorf("empty builds regressed: %v", regressed)
+	}
+
+	// Anything appearing from nothing counts as fully new, so it is beyond any tolerance.
+	after := sizeReport(1, 1000, 5000, 500, 6000, 6000)
+	deltas, regressed := CompareSizes(before, after, 5)
+	if want := []string{"html", "js", "content_data", "largest_route", "first_paint"}; !reflect.DeepEqual(regressed, want) {
+		t.Errorf("regressed = %v, want %v", regressed, want)
+	}
+	for _, delta := range deltas {
+		if delta.TotalPercent != 100 {
+			t.Errorf("%s total changed %.1f%%, want 100%%", delta.Category, delta.TotalPercent)
+		}
+	}
+}
+
+func TestPercentChange(t *testing.T) {
+	tests := []struct {
+		before, after int64
+		want          float64
+	}{
+		{0, 0, 0},
+		{0, 10, 100},
+		{100, 0, -100},
+		{100, 100, 0},
+		{100, 105, 5},
+		{100, 95, -5},
+		{1000, 1056, 5.6},
+		{3, 4, 33.3},
+	}
+	for _, test := range tests {
+		if got := percentChange(test.before, test.after); got != test.want {
+			t.Errorf("percentChange(%d, %d) = %v, want %v", test.before, test.after, got, test.want)
+		}
+	}
+}
+
+func TestReadSizeBaseline(t *testing.T) {
+	dir, err := ioutil.TempDir("", "plenti-size-baseline")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	baselinePath := filepath.Join(dir, "sizes.json")
+
+	if _, err = readSizeBaseline(baselinePath); !os.IsNotExist(err) {
+		t.Errorf("missing baseline error = %v, want not exist", err)
+	}
+
+	report := sizeReport(2, 2000, 3000, 1000, 4000, 5000)
+	report.Routes = map[string]int64{"/": 2500, "/about/": 2500}
+	if err = writeSizeBaseline(baselinePath, report); err != nil {
+		t.Fatal(err)
+	}
+	read, err := readSizeBaseline(baselinePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(read, report) {
+		t.Errorf("read %+v, want %+v", read, report)
+	}
+
+	if err = ioutil.WriteFile(baselinePath, []byte(`{"version": 99, "pages": 1}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = readSizeBaseline(baselinePath); err == nil || !strings.Contains(err.Error(), "version 99") {
+		t.Errorf("version mismatch error = %v, want one naming version 99", err)
+	}
+
+	if err = ioutil.WriteFile(baselinePath, []byte(`not json`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = readSizeBaseline(baselinePath); err == nil {
+		t.Error("invalid baseline was read without an error")
+	}
+}
+
+// Writes a build directory with the given files, keyed by their path below it.
+func writeBuildFixture(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "plenti-measure-sizes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, content := range files {
+		if err = os.MkdirAll(filepath.Dir(dir+path), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err = ioutil.WriteFile(dir+path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestMeasureSizes(t *testing.T) {
+	files := map[string]string{
+		"/index.html":             `<html><head><script src="/spa/ejected/main.js"></script><link rel="preload" href="/spa/ejected/content.js"></head></html>`,
+		"/about/index.html":       `<html><img src="/assets/logo.svg"></html>`,
+		"/spa/ejected/main.js":    strings.Repeat("a", 100),
+		"/spa/ejected/content.js": strings.Repeat("d", 80),
+		"/spa/layout.js":          strings.Repeat("b", 50),
+		"/assets/logo.svg":        strings.Repeat("c", 30),
+	}
+	dir := writeBuildFixture(t, files)
+	defer os.RemoveAll(dir)
+
+	report, err := MeasureSizes(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	home := int64(len(files["/index.html"]))
+	about := int64(len(files["/about/index.html"]))
+	if report.Pages != 2 {
+		t.Errorf("pages = %d, want 2", report.Pages)
+	}
+	if js := report.Categories["js"]; js.Total != 150 || js.Average != 150 {
+		t.Errorf("js = %+v, want a total of 150 on every page", js)
+	}
+	if contentData := report.Categories["content_data"]; contentData.Total != 80 || contentData.Average != 40 {
+		t.Errorf("content data = %+v, want a total of 80 and 40 per page", contentData)
+	}
+	if html := report.Categories["html"]; html.Total != home+about || html.Average != (home+about)/2 {
+		t.Errorf("html = %+v", html)
+	}
+	if got, want := report.Categories["largest_route"].Total, home+100; got != want {
+		t.Errorf("largest route = %d, want %d (the content data source is measured on its own)", got, want)
+	}
+	if got, want := report.Routes["/about/"], about; got != want {
+		t.Errorf("about first paint = %d, want %d (images aren't part of first paint)", got, want)
+	}
+}
+
+// Adding pages grows the data source by a node per page, which must not count as a regression.
+func TestMeasureSizesContentGrowth(t *testing.T) {
+	site := func(pages int) map[string]string {
+		files := map[string]string{
+			"/spa/ejected/main.js": strings.Repeat("a", 1000),
+		}
+		nodes := []string{}
+		for i := 0; i < pages; i++ {
+			route := "/page-" + strconv.Itoa(i) + "/"
+			files[route+"index.html"] = `<html><head><script src="/spa/ejected/main.js"></script></head><body>` + strings.Repeat("p", 200) + `</body></html>`
+			nodes = append(nodes, `{"path": "`+route+`", "fields": {"body": "`+strings.Repeat("p", 200)+`"}}`)
+		}
+		files["/spa/ejected/content.js"] = "const contentSource = [" + strings.Join(nodes, ",") + "];\n\nexport default contentSource;"
+		return files
+	}
+
+	beforeDir := writeBuildFixture(t, site(5))
+	defer os.RemoveAll(beforeDir)
+	afterDir := writeBuildFixture(t, site(50))
+	defer os.RemoveAll(afterDir)
+
+	before, err := MeasureSizes(beforeDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	after, err := MeasureSizes(afterDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.Categories["content_data"].Total <= before.Categories["content_data"].Total*5 {
+		t.Fatalf("content data grew from %d to %d, want the fixture to grow with content", before.Categories["content_data"].Total, after.Categories["content_data"].Total)
+	}
+	if _, regressed := CompareSizes(before, after, 5); len(regressed) != 0 {
+		t.Errorf("content growth regressed: %v", regressed)
+	}
+}
@@ -22,6 +22,7 @@ func ThemesMerge(tempBuildDir string, buildDir string) error {
 		".git",
 		".gitignore",
 		"themes",
+		".plenti",
 		strings.TrimSuffix(tempBuildDir, "/"),
 		buildDir,
 	}
@@ -0,0 +1,394 @@
+package cmd
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"plenti/cmd/build"
+	"plenti/generated"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Local files referenced by a page, e.g. src="/spa/ejected/main.js" or href="/assets/logo.svg".
+var reReference = regexp.MustCompile(`(?:src|href)="(/[^"#?]*\.[a-z0-9]+)"`)
+
+// Whitespace between tags that minify-html collapses, and the elements where it's left alone.
+var (
+	reIndentedTag = regexp.MustCompile(`>\s*\n[ \t]+<`)
+	rePre         = regexp.MustCompile(`(?s)<pre\b.*?</pre>`)
+)
+
+// Creates the starter site from "plenti new site" in a temporary folder and moves into it.
+func starterSite(t *testing.T) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "plenti-starter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		os.Chdir(wd)
+		os.RemoveAll(dir)
+	})
+	files := map[string][]byte{}
+	for file, content := range generated.Defaults {
+		files[file] = content
+	}
+	for file, content := range generated.Defaults_node_modules {
+		files["node_modules/"+strings.TrimPrefix(file, "/")] = content
+	}
+	for file, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(strings.TrimPrefix(file, "/")))
+		if err = os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err = ioutil.WriteFile(path, content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBuildOptimizedStarterSite(t *testing.T) {
+	starterSite(t)
+	OptimizeFlag = true
+	t.Cleanup(func() { OptimizeFlag = false })
+
+	// Verification failures stop the build with log.Fatal, which fails the test too.
+	Build()
+
+	stampBytes, err := ioutil.ReadFile("public/plenti_stamp.json")
+	if err != nil {
+		t.Fatalf("build didn't finish: %v", err)
+	}
+	var stamp build.Stamp
+	if err = json.Unmarshal(stampBytes, &stamp); err != nil {
+		t.Fatal(err)
+	}
+	options := []string{build.OptimizeMinifyHTML, build.OptimizeCompress, build.OptimizePreload}
+	if !reflect.DeepEqual(stamp.Optimize, options) {
+		t.Fatalf("build used options %v, want %v", stamp.Optimize, options)
+	}
+	enabled := []build.OptimizeOption{}
+	for _, option := range options {
+		enabled = append(enabled, build.OptimizeOption{Name: option, Enabled: true})
+	}
+	if err = build.VerifyOptimized("public", enabled); err != nil {
+		t.Fatal(err)
+	}
+
+	pages := []string{}
+	filepath.Walk("public", func(path string, info os.FileInfo, err error) error {
+		if err == nil && filepath.Ext(path) == ".html" {
+			pages = append(pages, filepath.ToSlash(strings.TrimPrefix(path, "public")))
+		}
+		return nil
+	})
+	if len(pages) < 5 {
+		t.Fatalf("only built pages %v", pages)
+	}
+	if _, err = os.Stat("public/spa/web_modules/svelte/internal/index.js.gz"); err != nil {
+		t.Error("svelte/internal wasn't compressed")
+	}
+
+	server := httptest.NewServer(serveHandler("public"))
+	defer server.Close()
+	get := func(path string) (string, string) {
+		response, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer response.Body.Close()
+		body, err := ioutil.ReadAll(response.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if response.StatusCode != http.StatusOK {
+			t.Errorf("GET %s: %s", path, response.Status)
+		}
+		return string(body), response.Header.Get("Content-Type")
+	}
+
+	entries, err := build.ClientEntries("public")
+	if err != nil {
+		t.Fatal(err)
+	}
+	graph, err := build.ReadModuleGraph("public", entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	references := map[string]bool{}
+	for modulePath := range graph.Modules {
+		references[modulePath] = true
+	}
+	for _, page := range pages {
+		url := strings.TrimSuffix(page, "index.html")
+		html, contentType := get(url)
+		if !strings.HasPrefix(contentType, "text/html") {
+			t.Errorf("GET %s: content type %s", url, contentType)
+		}
+		if page == "/404.html" {
+			continue
+		}
+		if !strings.Contains(html, `<link rel="modulepreload" href="/spa/ejected/router.js">`) {
+			t.Errorf("%s doesn't preload the modules of main.js", page)
+		}
+		if reIndentedTag.MatchString(rePre.ReplaceAllString(html, "")) {
+			t.Errorf("%s wasn't minified", page)
+		}
+		for _, match := range reReference.FindAllStringSubmatch(html, -1) {
+			references[match[1]] = true
+		}
+	}
+	for reference := range references {
+		body, contentType := get(reference)
+		if filepath.Ext(reference) == ".js" && (!strings.Contains(contentType, "javascript") || body == "") {
+			t.Errorf("GET %s: content type %s, %d bytes", reference, contentType, len(body))
+		}
+	}
+}
+
+// Reads every file of the build except the stamp, which has the time of the build.
+func buildSnapshot(t *testing.T, buildDir string) map[string]string {
+	t.Helper()
+	files := map[string]string{}
+	err := filepath.Walk(buildDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || info.Name() == "plenti_stamp.json" {
+			return err
+		}
+		content, err := ioutil.ReadFile(path)
+		files[filepath.ToSlash(strings.TrimPrefix(path, buildDir))] = string(content)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+// Builds a copy of the project from scratch, without anything left from previous builds.
+func fullBuildSnapshot(t *testing.T) map[string]string {
+	t.Helper()
+	project, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "plenti-full")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	err = filepath.Walk(project, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relative := strings.TrimPrefix(path, project)
+		if relative == "/public" || relative == "/.plenti" {
+			return filepath.SkipDir
+		}
+		if info.IsDir() {
+			return os.MkdirAll(dir+relative, os.ModePerm)
+		}
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(dir+relative, content, info.Mode())
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(project)
+	IncrementalFlag = false
+	defer func() { IncrementalFlag = true }()
+	Build()
+	return buildSnapshot(t, "public")
+}
+
+// Runs an incremental build after a change and checks it matches a full build, returning the pages that were written.
+func checkIncrementalBuild(t *testing.T, change func()) []string {
+	t.Helper()
+	// Backdate the pages so the ones the build writes can be told apart.
+	old := time.Now().Add(-time.Hour)
+	filepath.Walk("public", func(path string, info os.FileInfo, err error) error {
+		if err == nil && filepath.Ext(path) == ".html" {
+			os.Chtimes(path, old, old)
+		}
+		return nil
+	})
+	change()
+	Build()
+
+	written := []string{}
+	filepath.Walk("public", func(path string, info os.FileInfo, err error) error {
+		if err == nil && filepath.Ext(path) == ".html" && info.ModTime().After(old) {
+			written = append(written, filepath.ToSlash(strings.TrimPrefix(path, "public")))
+		}
+		return nil
+	})
+	sort.Strings(written)
+
+	incremental := buildSnapshot(t, "public")
+	full := fullBuildSnapshot(t)
+	for path, content := range full {
+		if _, ok := incremental[path]; !ok {
+			t.Errorf("incremental build is missing %s", path)
+		} else if incremental[path] != content {
+			t.Errorf("incremental build of %s differs from a full build:\n%s\nwant:\n%s", path, incremental[path], content)
+		}
+	}
+	for path := range incremental {
+		if _, ok := full[path]; !ok {
+			t.Errorf("incremental build has %s, which a full build doesn't", path)
+		}
+	}
+	return written
+}
+
+func writeSiteFile(t *testing.T, path string, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func replaceInSiteFile(t *testing.T, path string, old string, new string) {
+	t.Helper()
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), old) {
+		t.Fatalf("%s doesn't contain %q", path, old)
+	}
+	writeSiteFile(t, path, strings.Replace(string(content), old, new, 1))
+}
+
+func TestIncrementalBuild(t *testing.T) {
+	starterSite(t)
+	// Layouts that only render the content they are given, with pagination that depends on the index content.
+	replaceInSiteFile(t, "layout/global/footer.svelte", `{#each allContent as content}
+      <a href="{content.path}">{makeTitle(content.filename)}</a>
+    {/each}`, `<a href="/">{makeTitle("index.json")}</a>`)
+	replaceInSiteFile(t, "layout/content/index.svelte", `let allPosts = allContent.filter(content => content.type == "blog");`, `let allPosts = [];`)
+	replaceInSiteFile(t, "layout/content/index.svelte", `let totalPages = Math.ceil(totalPosts / postsPerPage);`, `let totalPages = intro.length;`)
+
+	IncrementalFlag = true
+	t.Cleanup(func() { IncrementalFlag = false })
+	Build()
+
+	// Paginated content is always rendered, since the number of pages is only known once it is.
+	pager := []string{"/1/index.html", "/2/index.html", "/3/index.html", "/index.html"}
+	tests := []struct {
+		name    string
+		change  func()
+		written []string
+	}{
+		{
+			name:    "nothing changed",
+			change:  func() {},
+			written: []string{},
+		},
+		{
+			name: "content field",
+			change: func() {
+				replaceInSiteFile(t, "content/blog/perry.json", `"title": "`, `"title": "Edited `)
+			},
+			written: append(append([]string{}, pager...), "/blog/perry/index.html"),
+		},
+		{
+			name: "content moved",
+			change: func() {
+				if err := os.Rename("content/pages/about.json", "content/pages/about-us.json"); err != nil {
+					t.Fatal(err)
+				}
+			},
+			written: append(append([]string{}, pager...), "/about-us/index.html"),
+		},
+		{
+			name: "content added",
+			change: func() {
+				writeSiteFile(t, "content/blog/new.json", `{"title": "New post", "body": ["Hello"], "author": "Me", "date": "1/1/2021"}`)
+			},
+			written: append(append([]string{}, pager...), "/blog/new/index.html"),
+		},
+		{
+			name: "content removed",
+			change: func() {
+				if err := os.Remove("content/blog/stores.json"); err != nil {
+					t.Fatal(err)
+				}
+			},
+			written: pager,
+		},
+		{
+			name: "pagination",
+			change: func() {
+				replaceInSiteFile(t, "content/index.json", `"intro": [`, `"intro": ["One more paragraph.", `)
+			},
+			written: []string{"/1/index.html", "/2/index.html", "/3/index.html", "/4/index.html", "/index.html"},
+		},
+		{
+			name: "asset",
+			change: func() {
+				replaceInSiteFile(t, "assets/logo.svg", "<svg", "<svg data-changed=\"true\"")
+				writeSiteFile(t, "assets/new.txt", "new asset")
+			},
+			written: []string{},
+		},
+		{
+			name: "layout",
+			change: func() {
+				replaceInSiteFile(t, "layout/content/pages.svelte", "<h1>", "<h1 class=\"changed\">")
+			},
+			// Every page is rendered again.
+			written: nil,
+		},
+	}
+	for _, test := range tests {
+		written := checkIncrementalBuild(t, test.change)
+		sort.Strings(test.written)
+		if test.written != nil && !reflect.DeepEqual(written, test.written) {
+			t.Errorf("%s: build wrote %v, want %v", test.name, written, test.written)
+		}
+		if test.written == nil && len(written) < 8 {
+			t.Errorf("%s: build only wrote %v", test.name, written)
+		}
+	}
+}
+
+func TestIncrementalBuildReadingAllContent(t *testing.T) {
+	// The starter site lists all content in the footer, so every page depends on every content file.
+	starterSite(t)
+	IncrementalFlag = true
+	t.Cleanup(func() { IncrementalFlag = false })
+	Build()
+
+	written := checkIncrementalBuild(t, func() {
+		if err := os.Rename("content/blog/perry.json", "content/blog/larry.json"); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if len(written) < 8 {
+		t.Errorf("build only wrote %v", written)
+	}
+}
@@ -24,6 +24,8 @@ that are used to create a plenti app. Some examples include:
 - router.svelte (handles all paths for clientside app)
 - main.js (the entry point for the app + sets up hydration for spa)
 - build.js (runs the svelte compiler to turn class instances into js components and html)
+- form.svelte (renders forms declared in plenti.json)
+- form_result.svelte (simple layout for form success and failure routes)
 
 You may want to edit this files directly if you need Plenti to do
 Something custom that it doesn't do out-of-the-box. However if you 
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"plenti/cmd/build"
+	"plenti/common"
+	"plenti/readers"
+	"plenti/writers"
+
+	"github.com/spf13/cobra"
+)
+
+// lockPath is where the resolved project inputs are recorded.
+const lockPath = "plenti.lock"
+
+// lockCmd represents the lock command
+var lockCmd = &cobra.Command{
+	Use:   "lock",
+	Short: "Manage the plenti.lock file",
+	Long: `The plenti.lock file records the exact inputs your site was
+last built with: the commit and checksum of each theme, the
+version of plenti and its ejectable core files, and a hash
+of your package-lock.json.
+
+It is kept up to date automatically when you build. Use
+"plenti build --frozen" to fail instead if anything drifted.`,
+}
+
+// checkLock compares the project against plenti.lock and returns the hash of the current inputs.
+func checkLock(siteConfig readers.SiteConfig, frozen bool) string {
+
+	current, err := build.Lock(siteConfig, Version)
+	if err != nil {
+		log.Fatalf("Could not resolve project inputs for %s: %v\n", lockPath, err)
+	}
+
+	drift, err := build.CheckLock(lockPath, current, frozen)
+	if err != nil {
+		if len(drift) > 0 {
+			fmt.Printf("The project no longer matches %s:\n", lockPath)
+			for _, change := range drift {
+				fmt.Println("  - " + change)
+			}
+		}
+		log.Fatal(err)
+	}
+	if len(drift) > 0 {
+		build.Log("Updating " + lockPath + " with changed inputs:")
+		for _, change := range drift {
+			build.Log("- " + change)
+		}
+		common.CheckErr(writers.SetLockfile(current, lockPath))
+	}
+
+	return build.LockHash(current)
+}
+
+func init() {
+	rootCmd.AddCommand(lockCmd)
+}
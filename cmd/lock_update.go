@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"plenti/cmd/build"
+	"plenti/readers"
+	"plenti/writers"
+
+	"github.com/spf13/cobra"
+)
+
+// lockUpdateCmd represents the lock update command
+var lockUpdateCmd = &cobra.Command{
+	Use:   "update",
+	Short: "Record the current project inputs in plenti.lock",
+	Long: `Update resolves the current themes, core version, and
+npm lockfile and rewrites plenti.lock with them.
+
+Run this after intentionally changing any of those inputs
+so that "plenti build --frozen" passes again.`,
+	Run: func(cmd *cobra.Command, args []string) {
+
+		// Get the current site configuration file values.
+		siteConfig, _ := readers.GetSiteConfig(".")
+
+		current, err := build.Lock(siteConfig, Version)
+		if err != nil {
+			log.Fatalf("Could not resolve project inputs for %s: %v\n", lockPath, err)
+		}
+
+		locked, _ := readers.GetLockfile(lockPath)
+		for _, change := range build.LockDrift(locked, current) {
+			fmt.Println("- " + change)
+		}
+
+		if err = writers.SetLockfile(current, lockPath); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Updated %s (%s)\n", lockPath, build.LockHash(current))
+
+	},
+}
+
+func init() {
+	lockCmd.AddCommand(lockUpdateCmd)
+}
@@ -79,9 +79,14 @@ var serveCmd = &cobra.Command{
 
 		fmt.Printf("\nServing site from your \"%v\" directory.\n", buildDir)
 
+		// Let pages send content edits back to the filesystem.
+		if EditFlag {
+			serveEdit("content")
+			fmt.Println("Editing is enabled, click on text in your browser to change it.")
+		}
+
 		// Point to folder containing the built site
-		fs := http.FileServer(http.Dir(buildDir))
-		http.Handle("/", fs)
+		http.Handle("/", serveHandler(buildDir))
 
 		// Check flags and config for local server port
 		port := setPort(siteConfig)
@@ -119,6 +124,14 @@ func init() {
 	serveCmd.Flags().BoolVarP(&VerboseFlag, "verbose", "v", false, "show log messages")
 	serveCmd.Flags().BoolVarP(&BenchmarkFlag, "benchmark", "b", false, "display build time statistics")
 	serveCmd.Flags().BoolVarP(&SSLFlag, "ssl", "s", false, "ssl/tls encryption to serve localhost over https")
+	serveCmd.Flags().BoolVarP(&EditFlag, "edit", "e", false, "edit content from the browser (local development only)")
+	serveCmd.Flags().BoolVar(&IncrementalFlag, "incremental", false, "only rebuild what changed since the last build when starting")
+	serveCmd.Flags().BoolVar(&OptimizeFlag, "optimize", false, "build with the recommended performance options, like in production")
+}
+
+// Serves the files of the build directory as they would be deployed.
+func serveHandler(buildDir string) http.Handler {
+	return http.FileServer(http.Dir(buildDir))
 }
 
 func serveSSL(port int) {
@@ -0,0 +1,218 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"plenti/cmd/build"
+	"regexp"
+	"strings"
+	"sync/atomic"
+)
+
+// EditFlag enables the in-browser content editing bridge while serving.
+var EditFlag bool
+
+// Number of rebuilds since the server started, polled by the editing bridge to know when to reload.
+var buildCount int64
+
+type editRequest struct {
+	Source string `json:"source"`
+	Field  string `json:"field"`
+	Value  string `json:"value"`
+}
+
+// Registers the endpoints used by the editing bridge (only when serving with --edit).
+func serveEdit(contentDir string) {
+	http.HandleFunc("/__plenti/edit", func(w http.ResponseWriter, r *http.Request) {
+		if err := checkEditRequest(r); err != nil {
+			editResponse(w, http.StatusForbidden, err)
+			return
+		}
+		if r.Method != http.MethodPost {
+			editResponse(w, http.StatusMethodNotAllowed, errors.New("Edits must be sent with POST"))
+			return
+		}
+		var edit editRequest
+		body := http.MaxBytesReader(w, r.Body, 1<<20)
+		if err := json.NewDecoder(body).Decode(&edit); err != nil {
+			editResponse(w, http.StatusBadRequest, fmt.Errorf("Could not read edit: %w", err))
+			return
+		}
+		builds := atomic.LoadInt64(&buildCount)
+		if err := saveEdit(contentDir, edit); err != nil {
+			editResponse(w, http.StatusBadRequest, err)
+			return
+		}
+		fmt.Printf("Saved edit to \"%s\" in content/%s\n", edit.Field, edit.Source)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"builds": %d}`, builds)
+	})
+	http.HandleFunc("/__plenti/edit/status", func(w http.ResponseWriter, r *http.Request) {
+		if err := checkEditRequest(r); err != nil {
+			editResponse(w, http.StatusForbidden, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"builds": %d}`, atomic.LoadInt64(&buildCount))
+	})
+}
+
+// Only accept requests made from the local machine by pages served from localhost.
+func checkEditRequest(r *http.Request) error {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return fmt.Errorf("Could not read remote address: %w", err)
+	}
+	if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+		return errors.New("Editing is only available from localhost")
+	}
+	if origin := r.Header.Get("Origin"); origin != "" {
+		originURL, err := url.Parse(origin)
+		if err != nil {
+			return errors.New("Invalid origin")
+		}
+		originHost := originURL.Hostname()
+		if originHost != "localhost" && originHost != "127.0.0.1" && originHost != "::1" {
+			return errors.New("Edits must come from a page served by plenti")
+		}
+	}
+	return nil
+}
+
+func editResponse(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	message, _ := json.Marshal(err.Error())
+	fmt.Fprintf(w, `{"error": %s}`, message)
+}
+
+// Validates an edit against the content schema and writes it back to the content file.
+func saveEdit(contentDir string, edit editRequest) error {
+	filePath, err := resolveContentPath(contentDir, edit.Source)
+	if err != nil {
+		return err
+	}
+	fileBytes, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("Could not read content file: %w", err)
+	}
+	if err = build.CheckEditableField(contentDir, edit.Source, fileBytes, edit.Field); err != nil {
+		return err
+	}
+	updatedBytes, err := setContentField(fileBytes, edit.Field, edit.Value)
+	if err != nil {
+		return err
+	}
+	// Writing the file triggers the watcher to rebuild the site.
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filePath, updatedBytes, info.Mode())
+}
+
+// Turns the source of a content node into a file path, refusing anything outside of the content directory.
+func resolveContentPath(contentDir string, source string) (string, error) {
+	if source == "" || strings.ContainsRune(source, 0) {
+		return "", errors.New("Missing content source")
+	}
+	if filepath.IsAbs(source) || strings.HasPrefix(source, "/") || strings.HasPrefix(source, `\`) {
+		return "", errors.New("Content source must be relative to the content directory")
+	}
+	cleanSource := filepath.Clean(filepath.FromSlash(source))
+	if cleanSource == ".." || strings.HasPrefix(cleanSource, ".."+string(filepath.Separator)) {
+		return "", errors.New("Content source must be inside the content directory")
+	}
+	fileName := filepath.Base(cleanSource)
+	if filepath.Ext(fileName) != ".json" || fileName[:1] == "_" || fileName[:1] == "." {
+		return "", errors.New("Only content .json files can be edited")
+	}
+
+	contentRoot, err := filepath.Abs(contentDir)
+	if err != nil {
+		return "", err
+	}
+	// Resolve symlinks so a link inside content/ can't point somewhere else.
+	contentRoot, err = filepath.EvalSymlinks(contentRoot)
+	if err != nil {
+		return "", fmt.Errorf("Could not find content directory: %w", err)
+	}
+	filePath, err := filepath.EvalSymlinks(filepath.Join(contentRoot, cleanSource))
+	if err != nil {
+		return "", fmt.Errorf("Could not find content file: %w", err)
+	}
+	relativePath, err := filepath.Rel(contentRoot, filePath)
+	if err != nil || relativePath == ".." || strings.HasPrefix(relativePath, ".."+string(filepath.Separator)) {
+		return "", errors.New("Content source must be inside the content directory")
+	}
+	info, err := os.Stat(filePath)
+	if err != nil || !info.Mode().IsRegular() {
+		return "", errors.New("Content source is not a file")
+	}
+	return filePath, nil
+}
+
+// Replaces a single top level value while leaving the rest of the file exactly as it was.
+func setContentField(fileBytes []byte, field string, value string) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(fileBytes))
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return nil, errors.New("Content file must contain a JSON object")
+	}
+	keys := []string{}
+	values := map[string]json.RawMessage{}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, fmt.Errorf("Could not read content file: %w", err)
+		}
+		key, _ := token.(string)
+		var raw json.RawMessage
+		if err = decoder.Decode(&raw); err != nil {
+			return nil, fmt.Errorf("Could not read content file: %w", err)
+		}
+		if _, exists := values[key]; !exists {
+			keys = append(keys, key)
+		}
+		values[key] = raw
+	}
+
+	// Encode without escaping HTML since content often contains markup.
+	var encoded bytes.Buffer
+	encoder := json.NewEncoder(&encoded)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return nil, err
+	}
+	values[field] = bytes.TrimSpace(encoded.Bytes())
+
+	// Reuse the indentation of the original file.
+	indent := "\t"
+	if match := regexp.MustCompile(`\n([ \t]+)"`).FindSubmatch(fileBytes); match != nil {
+		indent = string(match[1])
+	}
+
+	var updated bytes.Buffer
+	updated.WriteString("{\n")
+	for i, key := range keys {
+		keyBytes, _ := json.Marshal(key)
+		updated.WriteString(indent + string(keyBytes) + ": ")
+		updated.Write(values[key])
+		if i < len(keys)-1 {
+			updated.WriteString(",")
+		}
+		updated.WriteString("\n")
+	}
+	updated.WriteString("}")
+	if bytes.HasSuffix(fileBytes, []byte("\n")) {
+		updated.WriteString("\n")
+	}
+	return updated.Bytes(), nil
+}
@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Creates a project with a content directory next to files that must never be written by an edit.
+func editProject(t *testing.T) (string, string) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "plenti-edit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	files := map[string]string{
+		"plenti.json":                    `{"build": "public"}`,
+		"outside/secret.json":            `{"title": "secret"}`,
+		"content/index.json":             `{"title": "Home"}`,
+		"content/blog/_blueprint.json":   `{"title": "text", "body": "markdown", "date": "date", "tags": ["text"]}`,
+		"content/blog/post.json":         "{\n  \"title\": \"Post\",\n  \"body\": \"# Hello\",\n  \"date\": \"2020-01-01\",\n  \"tags\": [\"go\"],\n  \"draft\": \"no\"\n}\n",
+		"content/blog/notes.md":          `# Notes`,
+		"content/blog/.hidden.json":      `{"title": "hidden"}`,
+		"content/blog/folder.json/a.txt": `not a file`,
+	}
+	for path, content := range files {
+		path = filepath.Join(dir, filepath.FromSlash(path))
+		if err = os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err = ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Links inside content/ that point outside of it.
+	if err = os.Symlink(filepath.Join(dir, "outside"), filepath.Join(dir, "content", "linked")); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Symlink(filepath.Join(dir, "outside", "secret.json"), filepath.Join(dir, "content", "blog", "secret.json")); err != nil {
+		t.Fatal(err)
+	}
+	return dir, filepath.Join(dir, "content")
+}
+
+func TestResolveContentPath(t *testing.T) {
+	dir, contentDir := editProject(t)
+
+	rejected := map[string]string{
+		"empty":                       "",
+		"parent":                      "../plenti.json",
+		"parent of type":              "blog/../../plenti.json",
+		"parent of outside":           "../outside/secret.json",
+		"only parent":                 "..",
+		"absolute":                    "/etc/passwd.json",
+		"absolute project file":       filepath.Join(dir, "outside", "secret.json"),
+		"backslash":                   `\blog\post.json`,
+		"backslash parent":            `\..\plenti.json`,
+		"nul byte":                    "blog/post.json\x00.md",
+		"nul byte at end":             "blog/post.json\x00",
+		"symlinked folder":            "linked/secret.json",
+		"symlinked file":              "blog/secret.json",
+		"blueprint":                   "blog/_blueprint.json",
+		"blueprint through parent":    "blog/../blog/_blueprint.json",
+		"not json":                    "blog/notes.md",
+		"hidden":                      "blog/.hidden.json",
+		"folder":                      "blog/folder.json",
+		"missing":                     "blog/missing.json",
+		"json in another type folder": "blog/../../outside/secret.json",
+	}
+	for name, source := range rejected {
+		t.Run(name, func(t *testing.T) {
+			if path, err := resolveContentPath(contentDir, source); err == nil {
+				t.Errorf("resolveContentPath(%q) = %s, want an error", source, path)
+			}
+		})
+	}
+
+	path, err := resolveContentPath(contentDir, "blog/post.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := filepath.EvalSymlinks(filepath.Join(contentDir, "blog", "post.json"))
+	if path != want {
+		t.Errorf("path = %s, want %s", path, want)
+	}
+	if _, err = resolveContentPath(contentDir, "blog/./post.json"); err != nil {
+		t.Errorf("path with ./ was rejected: %v", err)
+	}
+}
+
+func TestSaveEdit(t *testing.T) {
+	dir, contentDir := editProject(t)
+	postPath := filepath.Join(contentDir, "blog", "post.json")
+
+	if err := saveEdit(contentDir, editRequest{Source: "blog/post.json", Field: "title", Value: "New <b>title</b>"}); err != nil {
+		t.Fatal(err)
+	}
+	post, _ := ioutil.ReadFile(postPath)
+	want := "{\n  \"title\": \"New <b>title</b>\",\n  \"body\": \"# Hello\",\n  \"date\": \"2020-01-01\",\n  \"tags\": [\"go\"],\n  \"draft\": \"no\"\n}\n"
+	if string(post) != want {
+		t.Errorf("post.json =\n%s\nwant\n%s", post, want)
+	}
+	if err := saveEdit(contentDir, editRequest{Source: "blog/post.json", Field: "body", Value: "## Markdown\n\nis *fine*"}); err != nil {
+		t.Errorf("markdown field was rejected: %v", err)
+	}
+
+	rejected := []struct {
+		name string
+		edit editRequest
+		err  string
+	}{
+		{"type that isn't whitelisted", editRequest{Source: "blog/post.json", Field: "date", Value: "tomorrow"}, `type "date"`},
+		{"list type", editRequest{Source: "blog/post.json", Field: "tags", Value: "go"}, "not a string"},
+		{"field missing from blueprint", editRequest{Source: "blog/post.json", Field: "draft", Value: "yes"}, "isn't in _blueprint.json"},
+		{"field missing from content", editRequest{Source: "blog/post.json", Field: "subtitle", Value: "new"}, "does not exist"},
+		{"no blueprint", editRequest{Source: "index.json", Field: "title", Value: "Hacked"}, "no _blueprint.json"},
+		{"traversal", editRequest{Source: "../outside/secret.json", Field: "title", Value: "Hacked"}, "inside the content directory"},
+		{"symlink", editRequest{Source: "linked/secret.json", Field: "title", Value: "Hacked"}, "inside the content directory"},
+		{"blueprint", editRequest{Source: "blog/_blueprint.json", Field: "title", Value: "markdown"}, "Only content .json files"},
+	}
+	for _, test := range rejected {
+		t.Run(test.name, func(t *testing.T) {
+			before, _ := ioutil.ReadFile(postPath)
+			err := saveEdit(contentDir, test.edit)
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("error = %v, want one containing %q", err, test.err)
+			}
+			if after, _ := ioutil.ReadFile(postPath); string(after) != string(before) {
+				t.Error("post.json changed after a rejected edit")
+			}
+		})
+	}
+
+	for _, path := range []string{"outside/secret.json", "content/index.json"} {
+		if content, _ := ioutil.ReadFile(filepath.Join(dir, path)); strings.Contains(string(content), "Hacked") {
+			t.Errorf("%s was written: %s", path, content)
+		}
+	}
+	if blueprint, _ := ioutil.ReadFile(filepath.Join(contentDir, "blog", "_blueprint.json")); !strings.Contains(string(blueprint), `"title": "text"`) {
+		t.Errorf("_blueprint.json was written: %s", blueprint)
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"os"
 	"path/filepath"
 	"plenti/cmd/build"
+	"plenti/readers"
+	"sync/atomic"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
@@ -60,6 +62,13 @@ func (w *watcher) watch(buildPath string) {
 
 	}
 
+	// Content stores outside of the filesystem (like sqlite) are polled for changes instead.
+	storeChanges := make(chan bool)
+	siteConfig, _ := readers.GetSiteConfig(".")
+	if err := build.WatchContentStores(siteConfig, func() { storeChanges <- true }); err != nil {
+		log.Fatalf("Error watching content stores for changes: %v\n", err)
+	}
+
 	done := make(chan bool)
 
 	// Set delay for batching events.
@@ -77,6 +86,9 @@ func (w *watcher) watch(buildPath string) {
 					// Add current event to array for batching.
 					events = append(events, event)
 				}
+			// Watch for changes in polled content stores.
+			case <-storeChanges:
+				events = append(events, fsnotify.Event{Name: "content store", Op: fsnotify.Write})
 			case <-ticker.C:
 				// Checks on set interval if there are events.
 				if len(events) > 0 {
@@ -99,8 +111,11 @@ func (w *watcher) watch(buildPath string) {
 							build.Log("File rename detected: " + event.String())
 						}
 					}
-					// Rebuild only one time for all batched events.
+					// Rebuild only one time for all batched events, reusing everything that didn't change.
+					IncrementalFlag = true
 					Build()
+					// Let the editing bridge know a new build is ready.
+					atomic.AddInt64(&buildCount, 1)
 					// Empty the batch array.
 					events = make([]fsnotify.Event, 0)
 
@@ -0,0 +1,113 @@
+import contentSource from './content.js';
+
+// Only loaded by "plenti serve --edit", never included in a production build.
+const endpoint = '/__plenti/edit';
+
+const getContent = (uri, trailingSlash = "") => {
+  return contentSource.find(content => content.path + trailingSlash == uri);
+}
+
+let content;
+// Elements that render a field, mapped to the name of that field.
+// The markers are added while rendering the page on the server, and collected before hydration removes them.
+const editable = new Map(window.plentiEditFields || []);
+
+const style = document.createElement('style');
+style.textContent = '[data-plenti-field] { cursor: pointer; outline: 1px dashed transparent; }' +
+  '[data-plenti-field]:hover { outline-color: #22a6ed; }' +
+  '#plenti-edit { position: fixed; right: 20px; bottom: 20px; width: 360px; max-width: 90vw; z-index: 10000;' +
+  ' padding: 15px; background: white; border: 1px solid #111; box-shadow: 0 2px 10px rgba(0,0,0,.3); font-family: sans-serif; }' +
+  '#plenti-edit textarea { width: 100%; min-height: 120px; box-sizing: border-box; }' +
+  '#plenti-edit .plenti-edit-error { color: #b00020; }';
+document.head.appendChild(style);
+
+const waitForRebuild = (build) => {
+  fetch(endpoint + '/status').then(response => response.json()).then(status => {
+    if (status.builds > build) {
+      location.reload();
+    } else {
+      setTimeout(() => waitForRebuild(build), 300);
+    }
+  }).catch(() => setTimeout(() => waitForRebuild(build), 1000));
+}
+
+const openPanel = (field, value) => {
+  const existing = document.getElementById('plenti-edit');
+  if (existing) {
+    existing.remove();
+  }
+  const panel = document.createElement('form');
+  panel.id = 'plenti-edit';
+  const label = document.createElement('label');
+  label.textContent = 'Edit "' + field + '" in content/' + content.source;
+  label.htmlFor = 'plenti-edit-value';
+  const input = document.createElement('textarea');
+  input.id = 'plenti-edit-value';
+  input.value = value;
+  const error = document.createElement('p');
+  error.className = 'plenti-edit-error';
+  error.setAttribute('role', 'alert');
+  const save = document.createElement('button');
+  save.type = 'submit';
+  save.textContent = 'Save';
+  const cancel = document.createElement('button');
+  cancel.type = 'button';
+  cancel.textContent = 'Cancel';
+  cancel.addEventListener('click', () => panel.remove());
+  panel.append(label, input, error, save, cancel);
+
+  panel.addEventListener('submit', event => {
+    event.preventDefault();
+    save.disabled = true;
+    fetch(endpoint, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ source: content.source, field: field, value: input.value })
+    }).then(response => response.json()).then(result => {
+      if (result.error) {
+        error.textContent = result.error;
+        save.disabled = false;
+        return;
+      }
+      save.textContent = 'Rebuilding...';
+      waitForRebuild(result.builds);
+    }).catch(err => {
+      error.textContent = 'Could not save: ' + err;
+      save.disabled = false;
+    });
+  });
+
+  document.body.appendChild(panel);
+  input.focus();
+}
+
+// Put the markers back for the hover outline once the app has hydrated the page.
+const mark = () => {
+  const uri = location.pathname;
+  content = getContent(uri) != undefined ? getContent(uri) : getContent(uri, "/");
+  editable.forEach((field, element) => element.setAttribute('data-plenti-field', field));
+}
+
+const findField = element => {
+  for (; element; element = element.parentElement) {
+    if (editable.has(element)) {
+      return editable.get(element);
+    }
+  }
+}
+
+document.addEventListener('click', event => {
+  const field = findField(event.target);
+  if (!field || !content || !content.source) {
+    return;
+  }
+  event.preventDefault();
+  event.stopPropagation();
+  openPanel(field, content.fields[field]);
+}, true);
+
+mark();
+addEventListener('load', () => setTimeout(mark, 500));
+// Pages rendered in the browser don't have markers, so load the page from the server after navigating.
+addEventListener('pushstate', () => location.reload());
+addEventListener('popstate', () => location.reload());
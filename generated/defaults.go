@@ -5,7 +5,8 @@ package generated
 // Defaults: scaffolding used in 'build' command
 var Defaults = map[string][]byte{
 	"/.gitignore": []byte(`public
-node_modules`),
+node_modules
+.plenti`),
 	"/assets/logo.svg": []byte(`<?xml version="1.0" encoding="UTF-8"?>
 <svg width="40" height="40" version="1.1" viewBox="0 0 10.583 10.583" xmlns="http://www.w3.org/2000/svg" xmlns:cc="http://creativecommons.org/ns#" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
  <metadata>
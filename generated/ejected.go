@@ -106,6 +106,271 @@ staticBuildStr.forEach(arg => {
 	fs.promises.writeFile(destPath, html);
 	  
 });`),
+	"/edit.js": []byte(`import contentSource from './content.js';
+
+// Only loaded by "plenti serve --edit", never included in a production build.
+const endpoint = '/__plenti/edit';
+
+const getContent = (uri, trailingSlash = "") => {
+  return contentSource.find(content => content.path + trailingSlash == uri);
+}
+
+let content;
+// Elements that render a field, mapped to the name of that field.
+// The markers are added while rendering the page on the server, and collected before hydration removes them.
+const editable = new Map(window.plentiEditFields || []);
+
+const style = document.createElement('style');
+style.textContent = '[data-plenti-field] { cursor: pointer; outline: 1px dashed transparent; }' +
+  '[data-plenti-field]:hover { outline-color: #22a6ed; }' +
+  '#plenti-edit { position: fixed; right: 20px; bottom: 20px; width: 360px; max-width: 90vw; z-index: 10000;' +
+  ' padding: 15px; background: white; border: 1px solid #111; box-shadow: 0 2px 10px rgba(0,0,0,.3); font-family: sans-serif; }' +
+  '#plenti-edit textarea { width: 100%; min-height: 120px; box-sizing: border-box; }' +
+  '#plenti-edit .plenti-edit-error { color: #b00020; }';
+document.head.appendChild(style);
+
+const waitForRebuild = (build) => {
+  fetch(endpoint + '/status').then(response => response.json()).then(status => {
+    if (status.builds > build) {
+      location.reload();
+    } else {
+      setTimeout(() => waitForRebuild(build), 300);
+    }
+  }).catch(() => setTimeout(() => waitForRebuild(build), 1000));
+}
+
+const openPanel = (field, value) => {
+  const existing = document.getElementById('plenti-edit');
+  if (existing) {
+    existing.remove();
+  }
+  const panel = document.createElement('form');
+  panel.id = 'plenti-edit';
+  const label = document.createElement('label');
+  label.textContent = 'Edit "' + field + '" in content/' + content.source;
+  label.htmlFor = 'plenti-edit-value';
+  const input = document.createElement('textarea');
+  input.id = 'plenti-edit-value';
+  input.value = value;
+  const error = document.createElement('p');
+  error.className = 'plenti-edit-error';
+  error.setAttribute('role', 'alert');
+  const save = document.createElement('button');
+  save.type = 'submit';
+  save.textContent = 'Save';
+  const cancel = document.createElement('button');
+  cancel.type = 'button';
+  cancel.textContent = 'Cancel';
+  cancel.addEventListener('click', () => panel.remove());
+  panel.append(label, input, error, save, cancel);
+
+  panel.addEventListener('submit', event => {
+    event.preventDefault();
+    save.disabled = true;
+    fetch(endpoint, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ source: content.source, field: field, value: input.value })
+    }).then(response => response.json()).then(result => {
+      if (result.error) {
+        error.textContent = result.error;
+        save.disabled = false;
+        return;
+      }
+      save.textContent = 'Rebuilding...';
+      waitForRebuild(result.builds);
+    }).catch(err => {
+      error.textContent = 'Could not save: ' + err;
+      save.disabled = false;
+    });
+  });
+
+  document.body.appendChild(panel);
+  input.focus();
+}
+
+// Put the markers back for the hover outline once the app has hydrated the page.
+const mark = () => {
+  const uri = location.pathname;
+  content = getContent(uri) != undefined ? getContent(uri) : getContent(uri, "/");
+  editable.forEach((field, element) => element.setAttribute('data-plenti-field', field));
+}
+
+const findField = element => {
+  for (; element; element = element.parentElement) {
+    if (editable.has(element)) {
+      return editable.get(element);
+    }
+  }
+}
+
+document.addEventListener('click', event => {
+  const field = findField(event.target);
+  if (!field || !content || !content.source) {
+    return;
+  }
+  event.preventDefault();
+  event.stopPropagation();
+  openPanel(field, content.fields[field]);
+}, true);
+
+mark();
+addEventListener('load', () => setTimeout(mark, 500));
+// Pages rendered in the browser don't have markers, so load the page from the server after navigating.
+addEventListener('pushstate', () => location.reload());
+addEventListener('popstate', () => location.reload());
+`),
+	"/form.svelte": []byte(`{#if form}
+<form
+  id={"form-" + form.name}
+  name={form.name}
+  action={form.action}
+  method="POST"
+  enctype={form.enctype}
+  data-netlify={form.netlify ? "true" : undefined}
+  netlify-honeypot={form.netlify ? form.honeypot : undefined}
+  novalidate={enhanced}
+  on:submit={submit}
+>
+  {#if form.netlify}
+    <input type="hidden" name="form-name" value={form.name} />
+  {/if}
+  <p class="honeypot" aria-hidden="true">
+    <label>Leave this field empty <input name={form.honeypot} tabindex="-1" autocomplete="off" /></label>
+  </p>
+  {#each form.fields as field}
+    <p class="field">
+      {#if field.type == "checkbox"}
+        <input
+          id={field.id}
+          name={field.name}
+          type="checkbox"
+          value="yes"
+          required={field.required}
+          aria-invalid={errors[field.name] ? "true" : undefined}
+          aria-describedby={field.id + "-error"}
+        />
+        <label for={field.id}>{field.label}{#if field.required}<span aria-hidden="true"> *</span>{/if}</label>
+      {:else}
+        <label for={field.id}>{field.label}{#if field.required}<span aria-hidden="true"> *</span>{/if}</label>
+        {#if field.type == "textarea"}
+          <textarea
+            id={field.id}
+            name={field.name}
+            required={field.required}
+            aria-invalid={errors[field.name] ? "true" : undefined}
+            aria-describedby={field.id + "-error"}
+          ></textarea>
+        {:else}
+          <input
+            id={field.id}
+            name={field.name}
+            type={field.type}
+            required={field.required}
+            aria-invalid={errors[field.name] ? "true" : undefined}
+            aria-describedby={field.id + "-error"}
+          />
+        {/if}
+      {/if}
+      <span id={field.id + "-error"} class="error" role="alert">{errors[field.name] || ""}</span>
+    </p>
+  {/each}
+  <p>
+    <button type="submit" disabled={sending}>{sending ? "Sending..." : "Send"}</button>
+  </p>
+  {#if form.mailto && form.enctype != "text/plain"}
+    <p class="mailto">You can also email us at <a href={"mailto:" + form.mailto}>{form.mailto}</a>.</p>
+  {/if}
+</form>
+{/if}
+
+<script>
+  import { onMount } from 'svelte';
+  import formsSource from './forms.js';
+
+  export let name;
+
+  const form = formsSource[name];
+  let enhanced = false;
+  let sending = false;
+  let errors = {};
+
+  // The plain HTML form works on its own, only take over submitting once JS has loaded.
+  onMount(() => {
+    enhanced = true;
+  });
+
+  // Mirror the constraints declared in plenti.json (these are also native HTML attributes).
+  const validate = formElement => {
+    errors = {};
+    form.fields.forEach(field => {
+      const input = formElement.elements[field.name];
+      if (input && !input.checkValidity()) {
+        errors[field.name] = input.validationMessage || field.label + " is not valid.";
+      }
+    });
+    return Object.keys(errors).length === 0;
+  }
+
+  const submit = event => {
+    // Mailto forms are handed off to the email client by the browser.
+    if (form.enctype == "text/plain") {
+      return;
+    }
+    event.preventDefault();
+    const formElement = event.target;
+    if (!validate(formElement)) {
+      const invalid = formElement.querySelector("[aria-invalid='true']");
+      if (invalid) {
+        invalid.focus();
+      }
+      return;
+    }
+    const data = new FormData(formElement);
+    // Bots fill in the hidden field, pretend it worked without sending anything.
+    if (data.get(form.honeypot)) {
+      location.href = form.success;
+      return;
+    }
+    sending = true;
+    fetch(form.submit, {
+      method: "POST",
+      headers: form.netlify ? { "Content-Type": "application/x-www-form-urlencoded" } : { "Accept": "application/json" },
+      body: form.netlify ? new URLSearchParams(data).toString() : data
+    }).then(response => {
+      location.href = response.ok ? form.success : form.failure;
+    }).catch(() => {
+      location.href = form.failure;
+    });
+  }
+</script>
+
+<style>
+  .honeypot {
+    position: absolute;
+    left: -10000px;
+    width: 1px;
+    height: 1px;
+    overflow: hidden;
+  }
+  .field label, .field input:not([type=checkbox]), .field textarea {
+    display: block;
+  }
+  .error {
+    color: #b00020;
+  }
+</style>
+`),
+	"/form_result.svelte": []byte(`<script>
+  export let title, message;
+</script>
+
+<h1>{title}</h1>
+
+<p>{message}</p>
+
+<p><a href="/">Back home</a></p>
+`),
 	"/main.js": []byte(`import Router from './router.svelte';
 import contentSource from './content.js';
 import * as allComponents from './layout.js';
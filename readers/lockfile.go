@@ -0,0 +1,53 @@
+package readers
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// Lockfile is the resolved version of every project input recorded in plenti.lock.
+type Lockfile struct {
+	Entries map[string]string
+}
+
+// GetLockfile reads the entries of a plenti.lock file.
+func GetLockfile(lockPath string) (Lockfile, error) {
+
+	lockfile := Lockfile{Entries: map[string]string{}}
+
+	lockBytes, err := ioutil.ReadFile(lockPath)
+	if err != nil {
+		return lockfile, err
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(lockBytes))
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		// Skip blank lines and comments.
+		if line == "" || line[:1] == "#" {
+			continue
+		}
+		// Entries are one per line so merges rarely conflict, but a conflict that wasn't resolved must not be read as entries.
+		for _, marker := range []string{"<<<<<<<", "=======", ">>>>>>>"} {
+			if strings.HasPrefix(line, marker) {
+				return lockfile, fmt.Errorf("Unresolved merge conflict on line %d of %s, resolve it or run \"plenti lock update\"", lineNumber, lockPath)
+			}
+		}
+		// Every entry is a single key followed by its value.
+		parts := strings.SplitN(line, " ", 2)
+		if len(parts) != 2 {
+			return lockfile, fmt.Errorf("Unable to read line %d of %s: %q", lineNumber, lockPath, line)
+		}
+		if _, exists := lockfile.Entries[parts[0]]; exists {
+			return lockfile, fmt.Errorf("Key %s is in %s more than once (line %d)", parts[0], lockPath, lineNumber)
+		}
+		lockfile.Entries[parts[0]] = parts[1]
+	}
+
+	return lockfile, scanner.Err()
+}
@@ -18,7 +18,10 @@ type SiteConfig struct {
 	Local       struct {
 		Port int `json:"port"`
 	} `json:"local"`
-	Types map[string]string `json:"types"`
+	Types         map[string]string              `json:"types"`
+	Forms         map[string]FormOptions         `json:"forms,omitempty"`
+	ContentStores map[string]ContentStoreOptions `json:"content_stores,omitempty"`
+	Optimize      bool                           `json:"optimize,omitempty"`
 }
 
 // ThemeOptions is the theme configuration information.
@@ -28,6 +31,39 @@ type ThemeOptions struct {
 	Exclude []string `json:"exclude,omitempty"`
 }
 
+// FormOptions is the configuration for a form declared in the site config.
+type FormOptions struct {
+	Fields  []FormField `json:"fields"`
+	Target  FormTarget  `json:"target"`
+	Success string      `json:"success,omitempty"`
+	Failure string      `json:"failure,omitempty"`
+}
+
+// FormField is a single input within a form.
+type FormField struct {
+	Name     string `json:"name"`
+	Label    string `json:"label,omitempty"`
+	Type     string `json:"type"`
+	Required bool   `json:"required,omitempty"`
+}
+
+// FormTarget is where form submissions get sent.
+type FormTarget struct {
+	Provider string `json:"provider,omitempty"`
+	Endpoint string `json:"endpoint,omitempty"`
+	Mailto   string `json:"mailto,omitempty"`
+	URL      string `json:"url,omitempty"`
+}
+
+// ContentStoreOptions configures where the content for a type is read from.
+type ContentStoreOptions struct {
+	Store string `json:"store"`
+	Path  string `json:"path"`
+	Table string `json:"table,omitempty"`
+	Query string `json:"query,omitempty"`
+	Key   string `json:"key,omitempty"`
+}
+
 // GetSiteConfig reads the site's configuration file values.
 func GetSiteConfig(basePath string) (SiteConfig, string) {
 
@@ -0,0 +1,38 @@
+package writers
+
+import (
+	"fmt"
+	"io/ioutil"
+	"plenti/readers"
+	"sort"
+	"strings"
+)
+
+// LockfileBytes serializes lock entries with sorted keys and one entry per line.
+func LockfileBytes(lockfile readers.Lockfile) []byte {
+
+	keys := make([]string, 0, len(lockfile.Entries))
+	for key := range lockfile.Entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var lockStr strings.Builder
+	lockStr.WriteString("# This file is generated by plenti, do not edit it by hand.\n")
+	lockStr.WriteString("# Run \"plenti lock update\" to intentionally refresh it.\n")
+	for _, key := range keys {
+		lockStr.WriteString(key + " " + lockfile.Entries[key] + "\n")
+	}
+	return []byte(lockStr.String())
+}
+
+// SetLockfile writes lock entries to the plenti.lock file.
+func SetLockfile(lockfile readers.Lockfile, lockPath string) error {
+
+	err := ioutil.WriteFile(lockPath, LockfileBytes(lockfile), 0644)
+	if err != nil {
+		return fmt.Errorf("Unable to write to lock file: %w", err)
+
+	}
+	return nil
+}